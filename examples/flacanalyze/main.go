@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/drgolem/go-flac/flac"
+)
+
+// frameStats is the per-frame record written in JSON mode.
+type frameStats struct {
+	Frame             int             `json:"frame"`
+	Offset            int64           `json:"offset"`
+	Size              int             `json:"size"`
+	SampleNumber      int64           `json:"sample_number"`
+	Blocksize         int             `json:"blocksize"`
+	SampleRate        int             `json:"sample_rate"`
+	Channels          int             `json:"channels"`
+	ChannelAssignment string          `json:"channel_assignment"`
+	BitsPerSample     int             `json:"bits_per_sample"`
+	CompressedBits    float64         `json:"compressed_bits_per_sample"`
+	HeaderCRC         uint8           `json:"header_crc"`
+	FooterCRC         uint16          `json:"footer_crc"`
+	Subframes         []subframeStats `json:"subframes"`
+}
+
+// subframeStats describes one channel of a frame.
+type subframeStats struct {
+	Channel           int      `json:"channel"`
+	Type              string   `json:"type"`
+	Order             int      `json:"order"`
+	WastedBits        int      `json:"wasted_bits"`
+	QLPCoeffPrecision int      `json:"qlp_coeff_precision,omitempty"`
+	QuantizationLevel int      `json:"quantization_level,omitempty"`
+	QLPCoeffs         []int32  `json:"qlp_coeffs,omitempty"`
+	ResidualMethod    string   `json:"residual_method,omitempty"`
+	PartitionOrder    int      `json:"partition_order"`
+	RiceParameters    []uint32 `json:"rice_parameters,omitempty"`
+	EscapedPartitions int      `json:"escaped_partitions"`
+}
+
+var csvHeader = []string{
+	"frame", "offset", "size", "sample_number", "blocksize", "sample_rate",
+	"channels", "channel_assignment", "bits_per_sample", "compressed_bits_per_sample",
+	"channel", "type", "order", "wasted_bits", "qlp_coeff_precision",
+	"quantization_level", "qlp_coeffs", "residual_method", "partition_order",
+	"rice_parameters", "escaped_partitions",
+}
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		fmt.Fprintln(os.Stderr, "usage: flacanalyze <infile.flac> [json|csv]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Writes per-frame and per-subframe statistics to stdout, similar to flac --analyze.")
+		fmt.Fprintln(os.Stderr, "json (default) writes one object per frame per line; csv writes one row per subframe.")
+		return
+	}
+
+	inFile := os.Args[1]
+	format := "json"
+	if len(os.Args) == 3 {
+		format = os.Args[2]
+	}
+	if format != "json" && format != "csv" {
+		slog.Error("Unknown output format", "format", format)
+		os.Exit(2)
+	}
+
+	dec, err := flac.NewFlacFrameDecoder(32)
+	if err != nil {
+		panic(err)
+	}
+	defer dec.Delete()
+
+	dec.SetAnalysisMode(true)
+	if err := dec.Open(inFile); err != nil {
+		slog.Error("Failed to open file", "error", err)
+		os.Exit(1)
+	}
+	defer dec.Close()
+
+	rate, channels, bitsPerSample := dec.GetFormat()
+	slog.Info("Analyzing", "file", inFile,
+		"sample_rate", rate, "channels", channels, "bits_per_sample", bitsPerSample,
+		"total_samples", dec.TotalSamples())
+
+	jsonOut := json.NewEncoder(os.Stdout)
+	csvOut := csv.NewWriter(os.Stdout)
+	if format == "csv" {
+		csvOut.Write(csvHeader)
+	}
+
+	typeCounts := map[string]int{}
+	var totalBytes, totalSamples int64
+
+	for i := 0; ; i++ {
+		frame, err := dec.DecodeFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			slog.Error("Failed to decode frame", "frame", i, "error", err)
+			os.Exit(1)
+		}
+
+		stats := newFrameStats(i, frame)
+		for _, sf := range stats.Subframes {
+			typeCounts[sf.Type]++
+		}
+		totalBytes += int64(frame.EncodedSize)
+		totalSamples += int64(frame.Blocksize * frame.Channels)
+
+		if format == "json" {
+			if err := jsonOut.Encode(stats); err != nil {
+				slog.Error("Failed to write output", "error", err)
+				os.Exit(1)
+			}
+		} else {
+			for _, row := range csvRows(stats) {
+				csvOut.Write(row)
+			}
+		}
+	}
+
+	csvOut.Flush()
+	if err := csvOut.Error(); err != nil {
+		slog.Error("Failed to write output", "error", err)
+		os.Exit(1)
+	}
+
+	var avgBits float64
+	if totalSamples > 0 {
+		avgBits = float64(totalBytes*8) / float64(totalSamples)
+	}
+	slog.Info("Analysis complete",
+		"constant", typeCounts["CONSTANT"],
+		"verbatim", typeCounts["VERBATIM"],
+		"fixed", typeCounts["FIXED"],
+		"lpc", typeCounts["LPC"],
+		"compressed_bits_per_sample", fmt.Sprintf("%.3f", avgBits))
+}
+
+func newFrameStats(index int, frame *flac.Frame) frameStats {
+	stats := frameStats{
+		Frame:             index,
+		Offset:            frame.StreamOffset,
+		Size:              frame.EncodedSize,
+		SampleNumber:      frame.SampleNumber,
+		Blocksize:         frame.Blocksize,
+		SampleRate:        frame.SampleRate,
+		Channels:          frame.Channels,
+		ChannelAssignment: frame.ChannelAssignment.String(),
+		BitsPerSample:     frame.BitsPerSample,
+		HeaderCRC:         frame.HeaderCRC,
+		FooterCRC:         frame.FooterCRC,
+	}
+	if n := frame.Blocksize * frame.Channels; n > 0 {
+		stats.CompressedBits = float64(frame.EncodedSize*8) / float64(n)
+	}
+
+	for ch, sf := range frame.Subframes {
+		s := subframeStats{
+			Channel:           ch,
+			Type:              sf.Type.String(),
+			Order:             sf.Order,
+			WastedBits:        sf.WastedBits,
+			QLPCoeffPrecision: sf.QLPCoeffPrecision,
+			QuantizationLevel: sf.QuantizationLevel,
+			QLPCoeffs:         sf.QLPCoeffs,
+		}
+		if sf.Residual != nil {
+			s.ResidualMethod = sf.Residual.Method.String()
+			s.PartitionOrder = sf.Residual.PartitionOrder
+			s.RiceParameters = sf.Residual.Parameters
+			for _, bits := range sf.Residual.RawBits {
+				if bits > 0 {
+					s.EscapedPartitions++
+				}
+			}
+		}
+		stats.Subframes = append(stats.Subframes, s)
+	}
+	return stats
+}
+
+func csvRows(stats frameStats) [][]string {
+	frameCols := []string{
+		strconv.Itoa(stats.Frame),
+		strconv.FormatInt(stats.Offset, 10),
+		strconv.Itoa(stats.Size),
+		strconv.FormatInt(stats.SampleNumber, 10),
+		strconv.Itoa(stats.Blocksize),
+		strconv.Itoa(stats.SampleRate),
+		strconv.Itoa(stats.Channels),
+		stats.ChannelAssignment,
+		strconv.Itoa(stats.BitsPerSample),
+		strconv.FormatFloat(stats.CompressedBits, 'f', 3, 64),
+	}
+
+	rows := make([][]string, 0, len(stats.Subframes))
+	for _, sf := range stats.Subframes {
+		row := append([]string(nil), frameCols...)
+		row = append(row,
+			strconv.Itoa(sf.Channel),
+			sf.Type,
+			strconv.Itoa(sf.Order),
+			strconv.Itoa(sf.WastedBits),
+			strconv.Itoa(sf.QLPCoeffPrecision),
+			strconv.Itoa(sf.QuantizationLevel),
+			joinInts(sf.QLPCoeffs),
+			sf.ResidualMethod,
+			strconv.Itoa(sf.PartitionOrder),
+			joinInts(sf.RiceParameters),
+			strconv.Itoa(sf.EscapedPartitions),
+		)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// joinInts formats a list of integers as a space-separated CSV field.
+func joinInts[T int32 | uint32](values []T) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatInt(int64(v), 10)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,209 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/format.h>
+#include <FLAC/stream_decoder.h>
+
+// The subframe and entropy coding data live in C unions, which cgo
+// exposes only as byte arrays; these helpers select the union members.
+
+static inline FLAC__int64
+subframe_constant_value(const FLAC__Subframe *subframe)
+{
+    return subframe->data.constant.value;
+}
+
+static inline const FLAC__Subframe_Fixed *
+subframe_fixed(const FLAC__Subframe *subframe)
+{
+    return &subframe->data.fixed;
+}
+
+static inline const FLAC__Subframe_LPC *
+subframe_lpc(const FLAC__Subframe *subframe)
+{
+    return &subframe->data.lpc;
+}
+
+static inline const FLAC__EntropyCodingMethod_PartitionedRice *
+entropy_partitioned_rice(const FLAC__EntropyCodingMethod *method)
+{
+    return &method->data.partitioned_rice;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// SubframeType is the prediction method used for one channel of a frame.
+type SubframeType int
+
+const (
+	SubframeConstant SubframeType = iota // every sample has the same value
+	SubframeVerbatim                     // samples stored unencoded
+	SubframeFixed                        // fixed polynomial predictor, order 0-4
+	SubframeLPC                          // linear predictor with quantized coefficients
+)
+
+func (t SubframeType) String() string {
+	switch t {
+	case SubframeConstant:
+		return "CONSTANT"
+	case SubframeVerbatim:
+		return "VERBATIM"
+	case SubframeFixed:
+		return "FIXED"
+	case SubframeLPC:
+		return "LPC"
+	default:
+		return fmt.Sprintf("SubframeType(%d)", int(t))
+	}
+}
+
+// EntropyCodingMethod is the residual coding of a FIXED or LPC subframe.
+type EntropyCodingMethod int
+
+const (
+	EntropyPartitionedRice  EntropyCodingMethod = iota // 4-bit Rice parameters
+	EntropyPartitionedRice2                            // 5-bit Rice parameters
+)
+
+func (m EntropyCodingMethod) String() string {
+	switch m {
+	case EntropyPartitionedRice:
+		return "PARTITIONED_RICE"
+	case EntropyPartitionedRice2:
+		return "PARTITIONED_RICE2"
+	default:
+		return fmt.Sprintf("EntropyCodingMethod(%d)", int(m))
+	}
+}
+
+// Subframe describes how one channel of a frame was encoded, as reported
+// by libFLAC in analysis mode (see SetAnalysisMode).
+type Subframe struct {
+	Type SubframeType
+
+	// WastedBits is the number of low-order zero bits removed from every
+	// sample before prediction.
+	WastedBits int
+
+	// ConstantValue is the sample value of a CONSTANT subframe.
+	ConstantValue int64
+
+	// Order is the predictor order of a FIXED or LPC subframe, and Warmup
+	// holds its Order unpredicted leading samples.
+	Order  int
+	Warmup []int64
+
+	// LPC only: the quantized predictor coefficients, their precision in
+	// bits and the quantization shift.
+	QLPCoeffs         []int32
+	QLPCoeffPrecision int
+	QuantizationLevel int
+
+	// Residual describes the coding of the prediction error of a FIXED or
+	// LPC subframe; nil for CONSTANT and VERBATIM.
+	Residual *ResidualCoding
+}
+
+// ResidualCoding is the partitioned Rice coding of a subframe's residual.
+type ResidualCoding struct {
+	Method EntropyCodingMethod
+
+	// The residual is split into 2^PartitionOrder partitions, each with
+	// its own Rice parameter.
+	PartitionOrder int
+	Parameters     []uint32
+
+	// RawBits is non-zero for escaped partitions, whose residuals are
+	// stored unencoded with that many bits each.
+	RawBits []uint32
+}
+
+// SetAnalysisMode enables or disables subframe analysis. When enabled,
+// frames returned by DecodeFrame carry their subframe details
+// (Frame.Subframes) and, where libFLAC can report the decode position,
+// their location and size in the stream, similar to flac --analyze.
+// Analysis adds per-frame allocations and is off by default.
+func (d *FlacDecoder) SetAnalysisMode(enabled bool) {
+	d.analysis = enabled
+}
+
+// readSubframes copies the subframe details of a frame.
+func readSubframes(frame *C.FLAC__Frame) []Subframe {
+	channels := int(frame.header.channels)
+	subframes := make([]Subframe, channels)
+	for ch := range subframes {
+		subframes[ch] = readSubframe(&frame.subframes[ch])
+	}
+	return subframes
+}
+
+func readSubframe(sf *C.FLAC__Subframe) Subframe {
+	s := Subframe{
+		Type:       SubframeType(sf._type),
+		WastedBits: int(sf.wasted_bits),
+	}
+
+	switch sf._type {
+	case C.FLAC__SUBFRAME_TYPE_CONSTANT:
+		s.ConstantValue = int64(C.subframe_constant_value(sf))
+
+	case C.FLAC__SUBFRAME_TYPE_FIXED:
+		fixed := C.subframe_fixed(sf)
+		s.Order = int(fixed.order)
+		s.Warmup = make([]int64, s.Order)
+		for i := range s.Warmup {
+			s.Warmup[i] = int64(fixed.warmup[i])
+		}
+		s.Residual = readResidualCoding(&fixed.entropy_coding_method)
+
+	case C.FLAC__SUBFRAME_TYPE_LPC:
+		lpc := C.subframe_lpc(sf)
+		s.Order = int(lpc.order)
+		s.QLPCoeffPrecision = int(lpc.qlp_coeff_precision)
+		s.QuantizationLevel = int(lpc.quantization_level)
+		s.Warmup = make([]int64, s.Order)
+		s.QLPCoeffs = make([]int32, s.Order)
+		for i := 0; i < s.Order; i++ {
+			s.Warmup[i] = int64(lpc.warmup[i])
+			s.QLPCoeffs[i] = int32(lpc.qlp_coeff[i])
+		}
+		s.Residual = readResidualCoding(&lpc.entropy_coding_method)
+	}
+
+	return s
+}
+
+func readResidualCoding(method *C.FLAC__EntropyCodingMethod) *ResidualCoding {
+	rice := C.entropy_partitioned_rice(method)
+	rc := &ResidualCoding{
+		Method:         EntropyCodingMethod(method._type),
+		PartitionOrder: int(rice.order),
+	}
+
+	if rice.contents == nil {
+		return rc
+	}
+	partitions := 1 << rc.PartitionOrder
+	params := unsafe.Slice((*uint32)(unsafe.Pointer(rice.contents.parameters)), partitions)
+	rawBits := unsafe.Slice((*uint32)(unsafe.Pointer(rice.contents.raw_bits)), partitions)
+	rc.Parameters = append([]uint32(nil), params...)
+	rc.RawBits = append([]uint32(nil), rawBits...)
+	return rc
+}
+
+// decodePosition returns libFLAC's current byte position in the stream,
+// or -1 if it cannot be determined (forward-only readers, Ogg streams).
+func (d *FlacDecoder) decodePosition() int64 {
+	var pos C.FLAC__uint64
+	if C.FLAC__stream_decoder_get_decode_position(d.decoder, &pos) == 0 {
+		return -1
+	}
+	return int64(pos)
+}
@@ -0,0 +1,153 @@
+package flac
+
+import (
+	"io"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestFlacDecoder_AnalysisMode(t *testing.T) {
+	// Silence, then a sine, then white-ish noise: exercises CONSTANT,
+	// predicted (FIXED/LPC) and poorly predictable subframes.
+	const numSamples = 3 * 4096
+	samples := make([]int32, numSamples)
+	seed := uint32(1)
+	for i := range samples {
+		switch {
+		case i < 4096:
+			samples[i] = 0
+		case i < 2*4096:
+			samples[i] = int32(12000 * math.Sin(float64(i)*0.05))
+		default:
+			seed = seed*1664525 + 1013904223
+			samples[i] = int32(int16(seed >> 16))
+		}
+	}
+	path := encodeSamplesFile(t, 44100, 1, 16, samples)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	dec.SetAnalysisMode(true)
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	var frames []*Frame
+	for {
+		f, err := dec.DecodeFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("DecodeFrame failed: %v", err)
+		}
+		frames = append(frames, f)
+	}
+	if len(frames) == 0 {
+		t.Fatal("no frames decoded")
+	}
+
+	if got := frames[0].Subframes[0].Type; got != SubframeConstant {
+		t.Errorf("first (silent) frame has subframe type %s, want CONSTANT", got)
+	}
+
+	var sawPredicted bool
+	for i, f := range frames {
+		if len(f.Subframes) != 1 {
+			t.Fatalf("frame %d has %d subframes, want 1", i, len(f.Subframes))
+		}
+		sf := f.Subframes[0]
+		switch sf.Type {
+		case SubframeFixed, SubframeLPC:
+			sawPredicted = true
+			if sf.Residual == nil {
+				t.Fatalf("frame %d: %s subframe without residual coding", i, sf.Type)
+			}
+			if n := 1 << sf.Residual.PartitionOrder; len(sf.Residual.Parameters) != n || len(sf.Residual.RawBits) != n {
+				t.Errorf("frame %d: %d parameters, %d raw bits for partition order %d",
+					i, len(sf.Residual.Parameters), len(sf.Residual.RawBits), sf.Residual.PartitionOrder)
+			}
+			if len(sf.Warmup) != sf.Order {
+				t.Errorf("frame %d: %d warmup samples for order %d", i, len(sf.Warmup), sf.Order)
+			}
+			if sf.Type == SubframeLPC && (len(sf.QLPCoeffs) != sf.Order || sf.QLPCoeffPrecision <= 0) {
+				t.Errorf("frame %d: LPC order %d with %d coefficients, precision %d",
+					i, sf.Order, len(sf.QLPCoeffs), sf.QLPCoeffPrecision)
+			}
+		case SubframeConstant, SubframeVerbatim:
+			if sf.Residual != nil {
+				t.Errorf("frame %d: %s subframe has residual coding", i, sf.Type)
+			}
+		default:
+			t.Errorf("frame %d: unexpected subframe type %s", i, sf.Type)
+		}
+
+		// Frames are contiguous in the file and end at its end.
+		if f.StreamOffset <= 0 || f.EncodedSize <= 0 {
+			t.Fatalf("frame %d: offset %d, size %d", i, f.StreamOffset, f.EncodedSize)
+		}
+		if i > 0 {
+			prev := frames[i-1]
+			if prev.StreamOffset+int64(prev.EncodedSize) != f.StreamOffset {
+				t.Errorf("frame %d starts at %d, previous ended at %d",
+					i, f.StreamOffset, prev.StreamOffset+int64(prev.EncodedSize))
+			}
+		}
+	}
+	if !sawPredicted {
+		t.Error("no FIXED or LPC subframes decoded")
+	}
+
+	last := frames[len(frames)-1]
+	if end := last.StreamOffset + int64(last.EncodedSize); end != info.Size() {
+		t.Errorf("last frame ends at %d, file size %d", end, info.Size())
+	}
+}
+
+func TestFlacDecoder_AnalysisModeOff(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 5000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	f, err := dec.DecodeFrame()
+	if err != nil {
+		t.Fatalf("DecodeFrame failed: %v", err)
+	}
+	if f.Subframes != nil || f.StreamOffset != -1 || f.EncodedSize != 0 {
+		t.Errorf("analysis fields set with analysis off: %d subframes, offset %d, size %d",
+			len(f.Subframes), f.StreamOffset, f.EncodedSize)
+	}
+}
+
+func TestSubframeType_StringUnit(t *testing.T) {
+	tests := map[SubframeType]string{
+		SubframeConstant: "CONSTANT",
+		SubframeVerbatim: "VERBATIM",
+		SubframeFixed:    "FIXED",
+		SubframeLPC:      "LPC",
+		SubframeType(7):  "SubframeType(7)",
+	}
+	for typ, want := range tests {
+		if got := typ.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
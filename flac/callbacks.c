@@ -59,6 +59,45 @@ get_md5_signature(FLAC__StreamMetadata *metadata, uint8_t *out)
     memcpy(out, metadata->data.stream_info.md5sum, 16);
 }
 
+extern FLAC__StreamMetadata_VorbisComment *
+get_vorbis_comment(FLAC__StreamMetadata *metadata)
+{
+    return &metadata->data.vorbis_comment;
+}
+
+extern FLAC__StreamMetadata_Picture *
+get_picture(FLAC__StreamMetadata *metadata)
+{
+    return &metadata->data.picture;
+}
+
+extern FLAC__StreamMetadata_Application *
+get_application(FLAC__StreamMetadata *metadata)
+{
+    return &metadata->data.application;
+}
+
+extern FLAC__StreamMetadata_SeekTable *
+get_seek_table(FLAC__StreamMetadata *metadata)
+{
+    return &metadata->data.seek_table;
+}
+
+extern int
+get_frame_number_type(const FLAC__Frame *frame)
+{
+    return frame->header.number_type;
+}
+
+extern FLAC__uint64
+get_frame_number(const FLAC__Frame *frame)
+{
+    if (frame->header.number_type == FLAC__FRAME_NUMBER_TYPE_FRAME_NUMBER) {
+        return frame->header.number.frame_number;
+    }
+    return frame->header.number.sample_number;
+}
+
 void
 decoderErrorCallback_cgo(const FLAC__StreamDecoder *decoder,
                  FLAC__StreamDecoderErrorStatus status,
@@ -86,3 +125,46 @@ decoderWriteCallback_cgo(const FLAC__StreamDecoder *decoder,
                 (FLAC__Frame *)frame,
                 (FLAC__int32 **)buffer, data);
 }
+
+FLAC__StreamDecoderReadStatus
+decoderReadCallback_cgo(const FLAC__StreamDecoder *decoder,
+                FLAC__byte buffer[],
+                size_t *bytes,
+                void *data)
+{
+    return decoderReadCallback((FLAC__StreamDecoder *)decoder,
+                buffer, bytes, data);
+}
+
+FLAC__bool
+decoderEofCallback_cgo(const FLAC__StreamDecoder *decoder, void *data)
+{
+    return decoderEofCallback((FLAC__StreamDecoder *)decoder, data);
+}
+
+FLAC__StreamDecoderSeekStatus
+decoderSeekCallback_cgo(const FLAC__StreamDecoder *decoder,
+                FLAC__uint64 absolute_byte_offset,
+                void *data)
+{
+    return decoderSeekCallback((FLAC__StreamDecoder *)decoder,
+                absolute_byte_offset, data);
+}
+
+FLAC__StreamDecoderTellStatus
+decoderTellCallback_cgo(const FLAC__StreamDecoder *decoder,
+                FLAC__uint64 *absolute_byte_offset,
+                void *data)
+{
+    return decoderTellCallback((FLAC__StreamDecoder *)decoder,
+                absolute_byte_offset, data);
+}
+
+FLAC__StreamDecoderLengthStatus
+decoderLengthCallback_cgo(const FLAC__StreamDecoder *decoder,
+                FLAC__uint64 *stream_length,
+                void *data)
+{
+    return decoderLengthCallback((FLAC__StreamDecoder *)decoder,
+                stream_length, data);
+}
@@ -0,0 +1,192 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxChannels is the largest channel count the FLAC format allows.
+const maxChannels = 8
+
+// SetChannelMap selects and reorders the decoded channels. Output channel
+// i carries stream channel channelMap[i]; channels may be repeated or left
+// out. For example, []int{1} extracts the right channel of a stereo file,
+// and []int{0, 1, 4, 5, 2, 3} reorders FLAC/WAVE 5.1 (FL FR FC LFE BL BR)
+// to FL FR BL BR FC LFE.
+//
+// Must be called before Open. Passing nil restores the stream's own
+// layout. Opening a stream with fewer channels than the map refers to
+// fails. A channel map replaces any matrix set with SetChannelMatrix.
+func (d *FlacDecoder) SetChannelMap(channelMap []int) error {
+	if d.channels != 0 {
+		return errors.New("channel layout must be set before Open")
+	}
+	if channelMap == nil {
+		d.channelMap = nil
+		return nil
+	}
+	if len(channelMap) < 1 || len(channelMap) > maxChannels {
+		return fmt.Errorf("invalid channel map length: %d (must be 1-%d)", len(channelMap), maxChannels)
+	}
+	for i, ch := range channelMap {
+		if ch < 0 || ch >= maxChannels {
+			return fmt.Errorf("invalid channel map entry %d: %d (must be 0-%d)", i, ch, maxChannels-1)
+		}
+	}
+
+	d.channelMap = append([]int(nil), channelMap...)
+	d.mixMatrix = nil
+	return nil
+}
+
+// SetChannelMatrix mixes the decoded channels through a matrix: output
+// channel i is the sum over j of matrix[i][j] times stream channel j. Each
+// row must have one coefficient per stream channel. Mixed samples are
+// rounded and clipped to the stream's bit depth. DownmixMatrix returns the
+// standard downmix matrices.
+//
+// Must be called before Open. Passing nil restores the stream's own
+// layout. Opening a stream whose channel count differs from the row length
+// fails. A matrix replaces any map set with SetChannelMap.
+func (d *FlacDecoder) SetChannelMatrix(matrix [][]float64) error {
+	if d.channels != 0 {
+		return errors.New("channel layout must be set before Open")
+	}
+	if matrix == nil {
+		d.mixMatrix = nil
+		return nil
+	}
+	if len(matrix) < 1 || len(matrix) > maxChannels {
+		return fmt.Errorf("invalid channel matrix: %d rows (must be 1-%d)", len(matrix), maxChannels)
+	}
+	inputs := len(matrix[0])
+	if inputs < 1 || inputs > maxChannels {
+		return fmt.Errorf("invalid channel matrix: %d columns (must be 1-%d)", inputs, maxChannels)
+	}
+
+	m := make([][]float64, len(matrix))
+	for i, row := range matrix {
+		if len(row) != inputs {
+			return fmt.Errorf("invalid channel matrix: row %d has %d columns, want %d", i, len(row), inputs)
+		}
+		for j, c := range row {
+			if math.IsNaN(c) || math.IsInf(c, 0) {
+				return fmt.Errorf("invalid channel matrix: coefficient [%d][%d] is %v", i, j, c)
+			}
+		}
+		m[i] = append([]float64(nil), row...)
+	}
+
+	d.mixMatrix = m
+	d.channelMap = nil
+	return nil
+}
+
+// DownmixMatrix returns the ITU-R BS.775 downmix matrix from inChannels to
+// outChannels, for use with SetChannelMatrix. Supported conversions are
+// stereo to mono (2→1), 5.1 to stereo (6→2) and 7.1 to stereo (8→2), with
+// input channels in FLAC/WAVE order:
+//
+//	5.1: FL FR FC LFE BL BR
+//	7.1: FL FR FC LFE BL BR SL SR
+//
+// The coefficients are the standard ones: front channels at 0 dB, centre
+// and surround channels at -3 dB, LFE dropped, and both channels at -3 dB
+// for mono. Loud material can clip with them; NormalizeMatrix scales a
+// matrix down so that it cannot.
+func DownmixMatrix(inChannels, outChannels int) ([][]float64, error) {
+	const minus3dB = 0.7071067811865476
+
+	var m [][]float64
+	switch {
+	case inChannels == 2 && outChannels == 1:
+		m = [][]float64{{minus3dB, minus3dB}}
+	case inChannels == 6 && outChannels == 2:
+		m = [][]float64{
+			{1, 0, minus3dB, 0, minus3dB, 0},
+			{0, 1, minus3dB, 0, 0, minus3dB},
+		}
+	case inChannels == 8 && outChannels == 2:
+		m = [][]float64{
+			{1, 0, minus3dB, 0, minus3dB, 0, minus3dB, 0},
+			{0, 1, minus3dB, 0, 0, minus3dB, 0, minus3dB},
+		}
+	default:
+		return nil, fmt.Errorf("no downmix from %d to %d channels", inChannels, outChannels)
+	}
+	return m, nil
+}
+
+// NormalizeMatrix returns a copy of matrix scaled so that no output
+// channel can exceed full scale: every coefficient is divided by the
+// largest sum of absolute coefficients in a row. The balance between
+// output channels is kept. A matrix that cannot clip is returned
+// unscaled.
+func NormalizeMatrix(matrix [][]float64) [][]float64 {
+	var peak float64
+	for _, row := range matrix {
+		var sum float64
+		for _, c := range row {
+			sum += math.Abs(c)
+		}
+		peak = max(peak, sum)
+	}
+
+	m := make([][]float64, len(matrix))
+	for i, row := range matrix {
+		m[i] = append([]float64(nil), row...)
+		if peak > 1 {
+			for j := range m[i] {
+				m[i][j] /= peak
+			}
+		}
+	}
+	return m
+}
+
+// configureChannels derives the output channel count from the stream's
+// channel count and the selected map or matrix.
+func (d *FlacDecoder) configureChannels() error {
+	d.channels = d.streamChannels
+
+	switch {
+	case d.mixMatrix != nil:
+		if len(d.mixMatrix[0]) != d.streamChannels {
+			return fmt.Errorf("channel matrix expects %d input channels, stream has %d", len(d.mixMatrix[0]), d.streamChannels)
+		}
+		d.channels = len(d.mixMatrix)
+	case d.channelMap != nil:
+		for _, ch := range d.channelMap {
+			if ch >= d.streamChannels {
+				return fmt.Errorf("channel map refers to channel %d, stream has %d channels", ch, d.streamChannels)
+			}
+		}
+		d.channels = len(d.channelMap)
+	}
+	return nil
+}
+
+// outputSample returns sample i of output channel ch, given the stream's
+// per-channel samples.
+func (d *FlacDecoder) outputSample(in [][]int32, ch, i int) int32 {
+	switch {
+	case d.mixMatrix != nil:
+		var sum float64
+		for j, c := range d.mixMatrix[ch] {
+			sum += c * float64(in[j][i])
+		}
+		v := math.Round(sum)
+		limit := float64(int64(1) << (d.bitsPerSample - 1))
+		if v >= limit {
+			v = limit - 1
+		} else if v < -limit {
+			v = -limit
+		}
+		return int32(v)
+	case d.channelMap != nil:
+		return in[d.channelMap[ch]][i]
+	default:
+		return in[ch][i]
+	}
+}
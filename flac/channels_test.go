@@ -0,0 +1,287 @@
+package flac
+
+import (
+	"io"
+	"math"
+	"slices"
+	"testing"
+)
+
+// decodeInt32All opens path with the given setup and decodes all samples.
+func decodeInt32All(t *testing.T, path string, setup func(*FlacDecoder) error) ([]int32, int) {
+	t.Helper()
+
+	dec, err := NewFlacFrameDecoder(32)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := setup(dec); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	_, channels, _ := dec.GetFormat()
+	total := int(dec.TotalSamples())
+	out := make([]int32, total*channels)
+	n, err := dec.DecodeInt32(total, out)
+	if err != nil && err != io.EOF {
+		t.Fatalf("DecodeInt32 failed: %v", err)
+	}
+	if n != total {
+		t.Fatalf("Decoded %d samples, want %d", n, total)
+	}
+	return out, channels
+}
+
+func TestFlacDecoder_ChannelMap(t *testing.T) {
+	const numSamples = 5000
+	path, orig := encodeTestFile(t, 44100, 2, 16, numSamples)
+
+	tests := []struct {
+		name string
+		m    []int
+	}{
+		{"swap", []int{1, 0}},
+		{"right", []int{1}},
+		{"duplicate", []int{0, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, channels := decodeInt32All(t, path, func(d *FlacDecoder) error { return d.SetChannelMap(tt.m) })
+			if channels != len(tt.m) {
+				t.Fatalf("GetFormat channels = %d, want %d", channels, len(tt.m))
+			}
+			for i := 0; i < numSamples; i++ {
+				for ch, src := range tt.m {
+					if got, want := out[i*channels+ch], orig[i*2+src]; got != want {
+						t.Fatalf("sample %d channel %d = %d, want %d", i, ch, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFlacDecoder_ChannelMatrixStereoToMono(t *testing.T) {
+	const numSamples = 5000
+	path, orig := encodeTestFile(t, 48000, 2, 24, numSamples)
+
+	m, err := DownmixMatrix(2, 1)
+	if err != nil {
+		t.Fatalf("DownmixMatrix failed: %v", err)
+	}
+	m = NormalizeMatrix(m)
+	out, channels := decodeInt32All(t, path, func(d *FlacDecoder) error { return d.SetChannelMatrix(m) })
+	if channels != 1 {
+		t.Fatalf("GetFormat channels = %d, want 1", channels)
+	}
+	for i, v := range out {
+		want := m[0][0]*float64(orig[2*i]) + m[0][1]*float64(orig[2*i+1])
+		if math.Abs(float64(v)-want) > 0.5+1e-9 {
+			t.Fatalf("sample %d = %d, want %v", i, v, want)
+		}
+	}
+}
+
+func TestFlacDecoder_ChannelMatrix51ToStereo(t *testing.T) {
+	const numSamples = 4096
+	path, orig := encodeTestFile(t, 48000, 6, 16, numSamples)
+
+	m, err := DownmixMatrix(6, 2)
+	if err != nil {
+		t.Fatalf("DownmixMatrix failed: %v", err)
+	}
+	m = NormalizeMatrix(m)
+	out, channels := decodeInt32All(t, path, func(d *FlacDecoder) error { return d.SetChannelMatrix(m) })
+	if channels != 2 {
+		t.Fatalf("GetFormat channels = %d, want 2", channels)
+	}
+	for i := 0; i < numSamples; i++ {
+		for ch := 0; ch < 2; ch++ {
+			var sum float64
+			for j := 0; j < 6; j++ {
+				sum += m[ch][j] * float64(orig[i*6+j])
+			}
+			if got := out[i*2+ch]; math.Abs(float64(got)-sum) > 0.5+1e-9 {
+				t.Fatalf("sample %d channel %d = %d, want %v", i, ch, got, sum)
+			}
+		}
+	}
+}
+
+func TestFlacDecoder_ChannelMapMismatch(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 1000)
+
+	for name, setup := range map[string]func(*FlacDecoder) error{
+		"map":    func(d *FlacDecoder) error { return d.SetChannelMap([]int{0, 2}) },
+		"matrix": func(d *FlacDecoder) error { return d.SetChannelMatrix([][]float64{{1, 0, 0}}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			dec, err := NewFlacFrameDecoder(16)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := setup(dec); err != nil {
+				t.Fatalf("setup failed: %v", err)
+			}
+			if err := dec.Open(path); err == nil {
+				t.Error("Open should fail when the layout does not match the stream")
+			}
+			dec.Close()
+		})
+	}
+}
+
+func TestSetChannelMap_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	for _, m := range [][]int{{}, {-1}, {8}, {0, 1, 2, 3, 4, 5, 6, 7, 0}} {
+		if err := dec.SetChannelMap(m); err == nil {
+			t.Errorf("SetChannelMap(%v) should fail", m)
+		}
+	}
+	if err := dec.SetChannelMap([]int{1, 0}); err != nil {
+		t.Errorf("SetChannelMap([1 0]) failed: %v", err)
+	}
+	if err := dec.SetChannelMap(nil); err != nil {
+		t.Errorf("SetChannelMap(nil) failed: %v", err)
+	}
+
+	// Setting the layout on an open decoder is rejected.
+	dec.channels = 2
+	if err := dec.SetChannelMap([]int{0}); err == nil {
+		t.Error("SetChannelMap on an open decoder should fail")
+	}
+}
+
+func TestSetChannelMatrix_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	invalid := [][][]float64{
+		{},
+		{{}},
+		{{1, 0}, {1}},
+		{{math.NaN(), 1}},
+		{{math.Inf(1)}},
+	}
+	for _, m := range invalid {
+		if err := dec.SetChannelMatrix(m); err == nil {
+			t.Errorf("SetChannelMatrix(%v) should fail", m)
+		}
+	}
+
+	m := [][]float64{{0.5, 0.5}}
+	if err := dec.SetChannelMatrix(m); err != nil {
+		t.Fatalf("SetChannelMatrix failed: %v", err)
+	}
+	// The matrix is copied.
+	m[0][0] = 3
+	if dec.mixMatrix[0][0] != 0.5 {
+		t.Error("SetChannelMatrix did not copy the matrix")
+	}
+}
+
+func TestDownmixMatrix_Unit(t *testing.T) {
+	for _, tc := range []struct{ in, out int }{{2, 1}, {6, 2}, {8, 2}} {
+		m, err := DownmixMatrix(tc.in, tc.out)
+		if err != nil {
+			t.Fatalf("DownmixMatrix(%d, %d) failed: %v", tc.in, tc.out, err)
+		}
+		if len(m) != tc.out {
+			t.Fatalf("DownmixMatrix(%d, %d) has %d rows", tc.in, tc.out, len(m))
+		}
+		for i, row := range m {
+			if len(row) != tc.in {
+				t.Fatalf("row %d has %d columns, want %d", i, len(row), tc.in)
+			}
+		}
+	}
+
+	// ITU-R BS.775 coefficients, unscaled.
+	const c = 0.7071067811865476
+	want51 := [][]float64{
+		{1, 0, c, 0, c, 0},
+		{0, 1, c, 0, 0, c},
+	}
+	if m, _ := DownmixMatrix(6, 2); !slices.EqualFunc(m, want51, slices.Equal) {
+		t.Errorf("5.1 downmix = %v, want %v", m, want51)
+	}
+	want71 := [][]float64{
+		{1, 0, c, 0, c, 0, c, 0},
+		{0, 1, c, 0, 0, c, 0, c},
+	}
+	if m, _ := DownmixMatrix(8, 2); !slices.EqualFunc(m, want71, slices.Equal) {
+		t.Errorf("7.1 downmix = %v, want %v", m, want71)
+	}
+	if m, _ := DownmixMatrix(2, 1); !slices.Equal(m[0], []float64{c, c}) {
+		t.Errorf("stereo to mono downmix = %v", m)
+	}
+
+	if _, err := DownmixMatrix(6, 1); err == nil {
+		t.Error("DownmixMatrix(6, 1) should fail")
+	}
+}
+
+func TestNormalizeMatrix_Unit(t *testing.T) {
+	m, _ := DownmixMatrix(6, 2)
+	n := NormalizeMatrix(m)
+	peak := 1 + 2*0.7071067811865476
+	for i, row := range n {
+		var sum float64
+		for j, c := range row {
+			sum += math.Abs(c)
+			if want := m[i][j] / peak; math.Abs(c-want) > 1e-12 {
+				t.Errorf("[%d][%d] = %v, want %v", i, j, c, want)
+			}
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Errorf("row %d sums to %v, want 1", i, sum)
+		}
+	}
+	if m[0][0] != 1 {
+		t.Error("NormalizeMatrix modified its argument")
+	}
+
+	// Rows are scaled together, so quieter rows stay quieter, and a
+	// matrix that cannot clip is left alone.
+	n = NormalizeMatrix([][]float64{{2, -2}, {1, 0}})
+	if !slices.Equal(n[0], []float64{0.5, -0.5}) || !slices.Equal(n[1], []float64{0.25, 0}) {
+		t.Errorf("NormalizeMatrix = %v", n)
+	}
+	n = NormalizeMatrix([][]float64{{0.5, 0.25}})
+	if !slices.Equal(n[0], []float64{0.5, 0.25}) {
+		t.Errorf("NormalizeMatrix scaled a matrix that cannot clip: %v", n)
+	}
+}
+
+func TestOutputSample_Unit(t *testing.T) {
+	in := [][]int32{{32767, -32768, 100}, {32767, -32768, -51}}
+
+	d := &FlacDecoder{bitsPerSample: 16, mixMatrix: [][]float64{{1, 1}, {0.5, 0.5}}}
+	want := [][]int32{{32767, -32768, 49}, {32767, -32768, 25}}
+	for ch := range want {
+		for i, w := range want[ch] {
+			if got := d.outputSample(in, ch, i); got != w {
+				t.Errorf("matrix output channel %d sample %d = %d, want %d", ch, i, got, w)
+			}
+		}
+	}
+
+	d = &FlacDecoder{bitsPerSample: 16, channelMap: []int{1}}
+	if got := d.outputSample(in, 0, 2); got != -51 {
+		t.Errorf("mapped sample = %d, want -51", got)
+	}
+}
@@ -45,6 +45,19 @@ encoder_init_stream_handle(FLAC__StreamEncoder *encoder,
     return FLAC__stream_encoder_init_stream(
         encoder, write_cb, NULL, NULL, metadata_cb, (void *)handle);
 }
+
+// encoder_init_ogg_stream_handle is the Ogg FLAC counterpart of
+// encoder_init_stream_handle. No read/seek/tell callbacks are given, so
+// libFLAC does not rewrite the header pages after encoding.
+static inline FLAC__StreamEncoderInitStatus
+encoder_init_ogg_stream_handle(FLAC__StreamEncoder *encoder,
+                               FLAC__StreamEncoderWriteCallback write_cb,
+                               FLAC__StreamEncoderMetadataCallback metadata_cb,
+                               uintptr_t handle)
+{
+    return FLAC__stream_encoder_init_ogg_stream(
+        encoder, NULL, write_cb, NULL, NULL, metadata_cb, (void *)handle);
+}
 */
 import "C"
 
@@ -52,17 +65,19 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"runtime/cgo"
 	"sync"
+	"time"
 	"unsafe"
 )
 
 // FlacEncoder provides FLAC encoding using libFLAC's stream encoder.
 //
-// It can encode either to a file (via EncodeFile) or to a callback-based
-// stream (via InitStream + ProcessInterleaved + Finish). The stream mode
-// is ideal for Ogg FLAC wrapping where each encoded frame is delivered
-// to a write callback.
+// It can encode either to a file (via InitFile) or to a callback-based
+// stream (via InitStream + ProcessInterleaved + Finish). InitOggFile and
+// InitOggStream produce the same output wrapped in Ogg pages, ready for
+// standard Ogg FLAC players.
 //
 // THREAD SAFETY: FlacEncoder is NOT thread-safe. All methods must be called
 // from a single goroutine. The internal write callback uses a mutex to
@@ -76,11 +91,30 @@ type FlacEncoder struct {
 	bitsPerSample    int
 	compressionLevel int
 
+	// Ogg serial number; a random one is chosen unless set explicitly
+	oggSerial    int64
+	oggSerialSet bool
+
 	// Stream mode: write callback collects encoded bytes here
 	mu        sync.Mutex
 	outBuf    []byte // accumulated output from write callbacks
 	lastError error
 
+	// Metadata blocks written after STREAMINFO (SetTags, AddPicture,
+	// AddApplication, SetPadding) and the libFLAC objects built from them,
+	// which must outlive the encode
+	tags         *Tags
+	pictures     []*Picture
+	applications []Application
+	padding      int
+	metadata     []*C.FLAC__StreamMetadata
+
+	// Seek table template (SetSeekTableInterval, SetSeekTablePoints),
+	// laid out over the total from SetTotalSamplesEstimate
+	seekInterval time.Duration
+	seekPoints   int
+	totalSamples uint64
+
 	// Metadata captured from metadata callback (after Finish)
 	streamInfo []byte // raw STREAMINFO block (34 bytes)
 
@@ -92,7 +126,7 @@ type FlacEncoder struct {
 // Parameters:
 //   - sampleRate: Sample rate in Hz (e.g., 44100, 48000, 96000)
 //   - channels: Number of audio channels (1-8)
-//   - bitsPerSample: Bit depth (8, 16, 24, or 32)
+//   - bitsPerSample: Bit depth (4-32; 8, 16 and 24 are the common ones)
 //
 // Returns the encoder instance or an error if parameters are invalid.
 func NewFlacEncoder(sampleRate, channels, bitsPerSample int) (*FlacEncoder, error) {
@@ -102,9 +136,8 @@ func NewFlacEncoder(sampleRate, channels, bitsPerSample int) (*FlacEncoder, erro
 	if channels < 1 || channels > 8 {
 		return nil, fmt.Errorf("invalid channels: %d (must be 1-8)", channels)
 	}
-	if bitsPerSample != bitDepth8 && bitsPerSample != bitDepth16 &&
-		bitsPerSample != bitDepth24 && bitsPerSample != bitDepth32 {
-		return nil, fmt.Errorf("invalid bitsPerSample: %d (must be 8, 16, 24, or 32)", bitsPerSample)
+	if bitsPerSample < minBitDepth || bitsPerSample > maxBitDepth {
+		return nil, fmt.Errorf("invalid bitsPerSample: %d (must be %d-%d)", bitsPerSample, minBitDepth, maxBitDepth)
 	}
 
 	enc := C.FLAC__stream_encoder_new()
@@ -135,6 +168,34 @@ func (e *FlacEncoder) SetCompressionLevel(level int) error {
 	return nil
 }
 
+// SetOggSerialNumber sets the serial number of the Ogg logical stream
+// written by InitOggFile and InitOggStream. If it is not called, a random
+// serial number is used, as the flac command line tool does.
+// Must be called before Init* methods.
+func (e *FlacEncoder) SetOggSerialNumber(serial int64) error {
+	if e.initialized {
+		return errors.New("cannot set Ogg serial number after initialization")
+	}
+	e.oggSerial = serial
+	e.oggSerialSet = true
+	return nil
+}
+
+// configureOgg applies the Ogg serial number. Called before Ogg init.
+func (e *FlacEncoder) configureOgg() error {
+	if !OggSupported() {
+		return ErrOggNotSupported
+	}
+	serial := e.oggSerial
+	if !e.oggSerialSet {
+		serial = int64(rand.Int32())
+	}
+	if C.FLAC__stream_encoder_set_ogg_serial_number(e.encoder, C.long(serial)) == 0 {
+		return errors.New("failed to set Ogg serial number")
+	}
+	return nil
+}
+
 // configureEncoder sets the encoder parameters. Called before init.
 func (e *FlacEncoder) configureEncoder() error {
 	if C.FLAC__stream_encoder_set_channels(e.encoder, C.uint32_t(e.channels)) == 0 {
@@ -152,7 +213,7 @@ func (e *FlacEncoder) configureEncoder() error {
 	if C.FLAC__stream_encoder_set_verify(e.encoder, C.FLAC__bool(1)) == 0 {
 		return errors.New("failed to enable verify")
 	}
-	return nil
+	return e.configureMetadata()
 }
 
 // InitFile initializes the encoder to write to a file.
@@ -174,7 +235,7 @@ func (e *FlacEncoder) InitFile(filePath string) error {
 
 	status := C.FLAC__stream_encoder_init_file(e.encoder, filename, nil, nil)
 	if status != C.FLAC__STREAM_ENCODER_INIT_STATUS_OK {
-		return fmt.Errorf("init encoder error: %s", getStreamEncoderInitStatusString(status))
+		return &EncoderError{Op: "init encoder", Status: EncoderInitStatus(status)}
 	}
 
 	e.initialized = true
@@ -183,7 +244,8 @@ func (e *FlacEncoder) InitFile(filePath string) error {
 
 // InitStream initializes the encoder in stream mode with write callback.
 // Encoded data is collected internally and returned via TakeBytes().
-// This mode is ideal for Ogg FLAC wrapping or piping to a network sink.
+// This mode is ideal for piping to a network sink; use InitOggStream for
+// Ogg FLAC output.
 func (e *FlacEncoder) InitStream() error {
 	if e.encoder == nil {
 		return errors.New("encoder not initialized")
@@ -206,7 +268,74 @@ func (e *FlacEncoder) InitStream() error {
 		C.uintptr_t(e.hEncoder),
 	)
 	if status != C.FLAC__STREAM_ENCODER_INIT_STATUS_OK {
-		return fmt.Errorf("init stream encoder error: %s", getStreamEncoderInitStatusString(status))
+		return &EncoderError{Op: "init stream encoder", Status: EncoderInitStatus(status)}
+	}
+
+	e.initialized = true
+	return nil
+}
+
+// InitOggFile initializes the encoder to write an Ogg FLAC file.
+// Call ProcessInterleaved to feed audio data, then Finish to finalize.
+func (e *FlacEncoder) InitOggFile(filePath string) error {
+	if e.encoder == nil {
+		return errors.New("encoder not initialized")
+	}
+	if e.initialized {
+		return errors.New("encoder already initialized")
+	}
+
+	if err := e.configureEncoder(); err != nil {
+		return err
+	}
+	if err := e.configureOgg(); err != nil {
+		return err
+	}
+
+	filename := C.CString(filePath)
+	defer C.free(unsafe.Pointer(filename))
+
+	status := C.FLAC__stream_encoder_init_ogg_file(e.encoder, filename, nil, nil)
+	if status != C.FLAC__STREAM_ENCODER_INIT_STATUS_OK {
+		return &EncoderError{Op: "init ogg encoder", Status: EncoderInitStatus(status)}
+	}
+
+	e.initialized = true
+	return nil
+}
+
+// InitOggStream initializes the encoder in stream mode, producing Ogg FLAC
+// pages. Encoded pages are collected internally and returned via TakeBytes().
+//
+// Since the output is not seekable, the STREAMINFO in the first page keeps
+// the values known at init time (use SetTotalSamplesEstimate to fill in the
+// total sample count).
+func (e *FlacEncoder) InitOggStream() error {
+	if e.encoder == nil {
+		return errors.New("encoder not initialized")
+	}
+	if e.initialized {
+		return errors.New("encoder already initialized")
+	}
+
+	if err := e.configureEncoder(); err != nil {
+		return err
+	}
+	if err := e.configureOgg(); err != nil {
+		return err
+	}
+
+	writeCallback := C.FLAC__StreamEncoderWriteCallback(unsafe.Pointer(C.encoderWriteCallback_cgo))
+	metadataCallback := C.FLAC__StreamEncoderMetadataCallback(unsafe.Pointer(C.encoderMetadataCallback_cgo))
+
+	status := C.encoder_init_ogg_stream_handle(
+		e.encoder,
+		writeCallback,
+		metadataCallback,
+		C.uintptr_t(e.hEncoder),
+	)
+	if status != C.FLAC__STREAM_ENCODER_INIT_STATUS_OK {
+		return &EncoderError{Op: "init ogg stream encoder", Status: EncoderInitStatus(status)}
 	}
 
 	e.initialized = true
@@ -223,6 +352,7 @@ func (e *FlacEncoder) SetTotalSamplesEstimate(totalSamples int64) error {
 	if C.FLAC__stream_encoder_set_total_samples_estimate(e.encoder, C.FLAC__uint64(totalSamples)) == 0 {
 		return errors.New("failed to set total samples estimate")
 	}
+	e.totalSamples = uint64(totalSamples)
 	return nil
 }
 
@@ -230,7 +360,8 @@ func (e *FlacEncoder) SetTotalSamplesEstimate(totalSamples int64) error {
 //
 // Each sample should be a signed int32 right-justified to bitsPerSample.
 // For 16-bit audio, samples should be in [-32768, 32767].
-// For 24-bit audio, samples should be in [-8388608, 8388607].
+// For 24-bit audio, samples should be in [-8388608, 8388607], and for
+// 20-bit audio in [-524288, 524287].
 //
 // The samples slice must contain numSamples * channels values.
 func (e *FlacEncoder) ProcessInterleaved(samples []int32, numSamples int) error {
@@ -250,15 +381,15 @@ func (e *FlacEncoder) ProcessInterleaved(samples []int32, numSamples int) error
 		C.uint32_t(numSamples),
 	)
 	if ok == 0 {
-		state := C.FLAC__stream_encoder_get_state(e.encoder)
-		return fmt.Errorf("process interleaved failed, encoder state: %d", state)
+		return e.encoderError("process interleaved")
 	}
 
 	return nil
 }
 
 // TakeBytes returns any encoded bytes accumulated from write callbacks
-// and clears the internal buffer. Only valid in stream mode (InitStream).
+// and clears the internal buffer. Only valid in stream mode (InitStream or
+// InitOggStream).
 func (e *FlacEncoder) TakeBytes() []byte {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -287,9 +418,10 @@ func (e *FlacEncoder) Finish() error {
 
 	ok := C.FLAC__stream_encoder_finish(e.encoder)
 	e.initialized = false
+	e.freeMetadata()
 
 	if ok == 0 {
-		return errors.New("encoder finish failed (possible verify mismatch)")
+		return e.encoderError("finish")
 	}
 	return nil
 }
@@ -304,6 +436,7 @@ func (e *FlacEncoder) Close() {
 		C.FLAC__stream_encoder_delete(e.encoder)
 		e.encoder = nil
 	}
+	e.freeMetadata()
 	if e.hEncoder != 0 {
 		e.hEncoder.Delete()
 		e.hEncoder = 0
@@ -418,56 +551,67 @@ func encoderMetadataCallback(
 	C.get_md5_signature(metadata, (*C.uint8_t)(unsafe.Pointer(&enc.streamInfo[18])))
 }
 
-func getStreamEncoderInitStatusString(status C.FLAC__StreamEncoderInitStatus) string {
-	var theCArray **C.char = (**C.char)(unsafe.Pointer(&C.FLAC__StreamEncoderInitStatusString))
-	length := 14 // number of status strings
-	slice := unsafe.Slice(theCArray, length)
-
-	idx := int(status)
-	if idx < 0 || idx >= length {
-		return fmt.Sprintf("unknown status %d", idx)
-	}
-	return C.GoString(slice[idx])
-}
-
 // PCMToInt32 converts interleaved little-endian PCM bytes to int32 samples.
 // This is a utility for converting raw PCM data to the format expected by
 // ProcessInterleaved.
 //
 // Parameters:
 //   - pcm: Raw PCM bytes (interleaved, little-endian)
-//   - bitsPerSample: Bit depth (8, 16, 24, or 32)
+//   - bitsPerSample: Bit depth; depths that are not a multiple of 8 are
+//     read from the next larger container (12-bit from 16-bit words)
 //   - out: Output slice for int32 samples (must be large enough)
 //
+// Samples of such depths are taken to be left-justified in their
+// container, as in WAV files and in the decoder's default output, so
+// decoded PCM can be fed back to the encoder unchanged. Use
+// PCMToInt32Justified for right-justified input.
+//
 // Returns the number of samples written to out.
 func PCMToInt32(pcm []byte, bitsPerSample int, out []int32) int {
-	bytesPerSample := bitsPerSample / 8
+	return PCMToInt32Justified(pcm, bitsPerSample, LeftJustified, out)
+}
+
+// PCMToInt32Justified is PCMToInt32 for samples placed in their container
+// as given by justification; see SampleJustification. It makes no
+// difference for depths that are a multiple of 8.
+func PCMToInt32Justified(pcm []byte, bitsPerSample int, justification SampleJustification, out []int32) int {
+	bytesPerSample := (bitsPerSample + 7) / 8
+	if bytesPerSample < 1 || bytesPerSample > 4 {
+		return 0
+	}
 	numSamples := len(pcm) / bytesPerSample
 	if numSamples > len(out) {
 		numSamples = len(out)
 	}
 
+	// Left-justified samples are brought down to their native magnitude.
+	var shift uint
+	if justification == LeftJustified {
+		shift = uint(bytesPerSample*8 - bitsPerSample)
+	}
+
 	for i := 0; i < numSamples; i++ {
 		off := i * bytesPerSample
+		var v int32
 		switch bytesPerSample {
 		case 1:
 			// 8-bit: signed (FLAC convention)
-			out[i] = int32(int8(pcm[off]))
+			v = int32(int8(pcm[off]))
 		case 2:
 			// 16-bit: signed little-endian
-			out[i] = int32(int16(pcm[off]) | int16(pcm[off+1])<<8)
+			v = int32(int16(pcm[off]) | int16(pcm[off+1])<<8)
 		case 3:
 			// 24-bit: signed little-endian
-			v := int32(pcm[off]) | int32(pcm[off+1])<<8 | int32(pcm[off+2])<<16
+			v = int32(pcm[off]) | int32(pcm[off+1])<<8 | int32(pcm[off+2])<<16
 			// Sign extend from 24-bit
 			if v&0x800000 != 0 {
 				v |= ^0xFFFFFF
 			}
-			out[i] = v
 		case 4:
 			// 32-bit: signed little-endian
-			out[i] = int32(pcm[off]) | int32(pcm[off+1])<<8 | int32(pcm[off+2])<<16 | int32(pcm[off+3])<<24
+			v = int32(pcm[off]) | int32(pcm[off+1])<<8 | int32(pcm[off+2])<<16 | int32(pcm[off+3])<<24
 		}
+		out[i] = v >> shift
 	}
 
 	return numSamples
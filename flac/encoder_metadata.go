@@ -0,0 +1,197 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/stream_encoder.h>
+#include <FLAC/metadata.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SetTags sets the Vorbis comments written to the VORBIS_COMMENT block.
+// The vendor string is always libFLAC's; tags.Vendor is ignored.
+// Must be called before Init* methods.
+func (e *FlacEncoder) SetTags(tags *Tags) error {
+	if e.initialized {
+		return errors.New("cannot set tags after initialization")
+	}
+	if tags != nil {
+		for _, f := range tags.Fields {
+			if err := validTagName(f.Name); err != nil {
+				return err
+			}
+		}
+		t := Tags{Fields: append([]TagField(nil), tags.Fields...)}
+		tags = &t
+	}
+	e.tags = tags
+	return nil
+}
+
+// AddPicture adds a PICTURE block, for example cover art. Pictures are
+// written in the order they are added.
+// Must be called before Init* methods.
+func (e *FlacEncoder) AddPicture(pic *Picture) error {
+	if e.initialized {
+		return errors.New("cannot add picture after initialization")
+	}
+	if pic == nil {
+		return errors.New("picture is nil")
+	}
+	// Build the block once to report an invalid picture here rather than
+	// from Init*.
+	obj, err := newPictureObject(pic)
+	if err != nil {
+		return err
+	}
+	C.FLAC__metadata_object_delete(obj)
+
+	p := *pic
+	p.Data = append([]byte(nil), pic.Data...)
+	e.pictures = append(e.pictures, &p)
+	return nil
+}
+
+// AddApplication adds an APPLICATION block holding data for the
+// application registered under id.
+// Must be called before Init* methods.
+func (e *FlacEncoder) AddApplication(id [4]byte, data []byte) error {
+	if e.initialized {
+		return errors.New("cannot add application block after initialization")
+	}
+	if len(data) > maxMetadataBlockLength-len(id) {
+		return fmt.Errorf("application data too large: %d bytes", len(data))
+	}
+	e.applications = append(e.applications, Application{ID: id, Data: append([]byte(nil), data...)})
+	return nil
+}
+
+// SetPadding adds a PADDING block of n bytes after the other metadata, so
+// tags and pictures can later be edited in place (see MetadataEditor).
+// 0 writes no padding, which is the default.
+// Must be called before Init* methods.
+func (e *FlacEncoder) SetPadding(n int) error {
+	if e.initialized {
+		return errors.New("cannot set padding after initialization")
+	}
+	if n < 0 || n > maxMetadataBlockLength {
+		return fmt.Errorf("invalid padding: %d (must be 0-%d)", n, maxMetadataBlockLength)
+	}
+	e.padding = n
+	return nil
+}
+
+// SetSeekTableInterval adds a SEEKTABLE block with a seek point every
+// interval of audio, like flac -S 10s. The points are laid out over the
+// total from SetTotalSamplesEstimate, which is required; 0 disables the
+// seek table. Replaces any SetSeekTablePoints setting.
+//
+// libFLAC fills in the seek points during Finish, which it can only do
+// for InitFile and InitOggFile; streams keep a table of placeholders.
+// Must be called before Init* methods.
+func (e *FlacEncoder) SetSeekTableInterval(interval time.Duration) error {
+	if e.initialized {
+		return errors.New("cannot set seek table after initialization")
+	}
+	if interval < 0 {
+		return fmt.Errorf("invalid seek table interval: %v", interval)
+	}
+	e.seekInterval = interval
+	e.seekPoints = 0
+	return nil
+}
+
+// SetSeekTablePoints adds a SEEKTABLE block with n evenly spaced seek
+// points, like flac -S 100x; 0 disables the seek table. Replaces any
+// SetSeekTableInterval setting; see it for the requirements.
+// Must be called before Init* methods.
+func (e *FlacEncoder) SetSeekTablePoints(n int) error {
+	if e.initialized {
+		return errors.New("cannot set seek table after initialization")
+	}
+	// Each point takes 18 bytes of a block of at most 2^24-1.
+	if n < 0 || n > maxMetadataBlockLength/18 {
+		return fmt.Errorf("invalid seek table points: %d (must be 0-%d)", n, maxMetadataBlockLength/18)
+	}
+	e.seekPoints = n
+	e.seekInterval = 0
+	return nil
+}
+
+// configureMetadata builds the metadata blocks set on the encoder and
+// hands them to libFLAC. Called before init; libFLAC reads the blocks
+// until Finish, after which freeMetadata releases them.
+func (e *FlacEncoder) configureMetadata() error {
+	e.freeMetadata()
+	seekTable := e.seekInterval > 0 || e.seekPoints > 0
+	if e.tags == nil && !seekTable && len(e.pictures) == 0 && len(e.applications) == 0 && e.padding == 0 {
+		return nil
+	}
+	if seekTable && e.totalSamples == 0 {
+		return errors.New("seek table requires SetTotalSamplesEstimate")
+	}
+
+	// VORBIS_COMMENT comes first, as Ogg FLAC requires.
+	if e.tags != nil {
+		obj, err := newVorbisCommentObject(e.tags)
+		if err != nil {
+			return err
+		}
+		e.metadata = append(e.metadata, obj)
+	}
+	if seekTable {
+		interval := 0
+		if e.seekInterval > 0 {
+			interval = min(max(1, int(e.seekInterval.Seconds()*float64(e.sampleRate)+0.5)), 1<<32-1)
+		}
+		obj, err := newSeekTableObject(interval, e.seekPoints, e.totalSamples)
+		if err != nil {
+			e.freeMetadata()
+			return err
+		}
+		e.metadata = append(e.metadata, obj)
+	}
+	for _, pic := range e.pictures {
+		obj, err := newPictureObject(pic)
+		if err != nil {
+			e.freeMetadata()
+			return err
+		}
+		e.metadata = append(e.metadata, obj)
+	}
+	for _, app := range e.applications {
+		obj, err := newApplicationObject(app)
+		if err != nil {
+			e.freeMetadata()
+			return err
+		}
+		e.metadata = append(e.metadata, obj)
+	}
+	if e.padding > 0 {
+		obj, err := newPaddingObject(e.padding)
+		if err != nil {
+			e.freeMetadata()
+			return err
+		}
+		e.metadata = append(e.metadata, obj)
+	}
+
+	if C.FLAC__stream_encoder_set_metadata(e.encoder, &e.metadata[0], C.uint32_t(len(e.metadata))) == 0 {
+		e.freeMetadata()
+		return errors.New("failed to set metadata")
+	}
+	return nil
+}
+
+// freeMetadata releases the blocks built by configureMetadata.
+func (e *FlacEncoder) freeMetadata() {
+	for _, obj := range e.metadata {
+		C.FLAC__metadata_object_delete(obj)
+	}
+	e.metadata = nil
+}
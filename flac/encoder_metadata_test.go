@@ -0,0 +1,159 @@
+package flac
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFlacEncoder_Metadata(t *testing.T) {
+	tags := &Tags{Vendor: "ignored", Fields: []TagField{{"ARTIST", "Someone"}, {"TITLE", "Ünïcödé"}}}
+	cover := &Picture{Type: PictureFrontCover, MIMEType: "image/png", Width: 1, Height: 1, Depth: 24, Data: []byte("\x89PNG")}
+	id := [4]byte{'t', 'e', 's', 't'}
+
+	path := filepath.Join(t.TempDir(), "meta.flac")
+	samples := generateTestSignal(5000, 2, 16)
+
+	enc, err := NewFlacEncoder(44100, 2, 16)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+	if err := enc.SetTags(tags); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	if err := enc.AddPicture(cover); err != nil {
+		t.Fatalf("AddPicture failed: %v", err)
+	}
+	if err := enc.AddApplication(id, []byte("payload")); err != nil {
+		t.Fatalf("AddApplication failed: %v", err)
+	}
+	if err := enc.SetPadding(2048); err != nil {
+		t.Fatalf("SetPadding failed: %v", err)
+	}
+	if err := enc.InitFile(path); err != nil {
+		t.Fatalf("InitFile failed: %v", err)
+	}
+	if err := enc.AddPicture(cover); err == nil {
+		t.Error("AddPicture after InitFile succeeded")
+	}
+	if err := enc.ProcessInterleaved(samples, 5000); err != nil {
+		t.Fatalf("ProcessInterleaved failed: %v", err)
+	}
+	if err := enc.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	m := openTestEditor(t, path)
+	var types []MetadataType
+	for _, b := range m.Blocks() {
+		types = append(types, b.Type)
+	}
+	want := []MetadataType{MetadataStreamInfo, MetadataVorbisComment, MetadataPicture, MetadataApplication, MetadataPadding}
+	if !slices.Equal(types, want) {
+		t.Errorf("blocks = %v, want %v", types, want)
+	}
+	got := m.Tags()
+	if got.Vendor == "" || got.Vendor == "ignored" {
+		t.Errorf("Vendor = %q, want libFLAC's", got.Vendor)
+	}
+	if !slices.Equal(got.Fields, tags.Fields) {
+		t.Errorf("Fields = %q, want %q", got.Fields, tags.Fields)
+	}
+	if pics := m.Pictures(); len(pics) != 1 || !equalPicture(pics[0], cover) {
+		t.Errorf("Pictures() = %+v, want %+v", pics, cover)
+	}
+	if apps := m.Applications(); len(apps) != 1 || apps[0].ID != id || string(apps[0].Data) != "payload" {
+		t.Errorf("Applications() = %+v", apps)
+	}
+	if got := m.Padding(); got != 2048 {
+		t.Errorf("Padding() = %d, want 2048", got)
+	}
+
+	if decoded, _ := decodeInt32All(t, path, nil); !slices.Equal(decoded, samples) {
+		t.Error("decoded samples differ")
+	}
+}
+
+func TestFlacEncoder_MetadataStream(t *testing.T) {
+	cover := &Picture{Type: PictureBackCover, MIMEType: "image/jpeg", Description: "back", Data: []byte{0xff, 0xd8}}
+	samples := generateTestSignal(5000, 1, 16)
+
+	encode := func(init func(*FlacEncoder) error) []byte {
+		enc, err := NewFlacEncoder(44100, 1, 16)
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %v", err)
+		}
+		defer enc.Close()
+		if err := enc.SetTags(&Tags{Fields: []TagField{{"ALBUM", "Stream"}}}); err != nil {
+			t.Fatalf("SetTags failed: %v", err)
+		}
+		if err := enc.AddPicture(cover); err != nil {
+			t.Fatalf("AddPicture failed: %v", err)
+		}
+		if err := init(enc); err != nil {
+			t.Fatalf("init failed: %v", err)
+		}
+		if err := enc.ProcessInterleaved(samples, 5000); err != nil {
+			t.Fatalf("ProcessInterleaved failed: %v", err)
+		}
+		if err := enc.Finish(); err != nil {
+			t.Fatalf("Finish failed: %v", err)
+		}
+		return enc.TakeBytes()
+	}
+
+	inits := map[string]func(*FlacEncoder) error{
+		"native": (*FlacEncoder).InitStream,
+	}
+	if OggSupported() {
+		inits["ogg"] = (*FlacEncoder).InitOggStream
+	}
+	for name, init := range inits {
+		t.Run(name, func(t *testing.T) {
+			dec, err := NewFlacFrameDecoder(16)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.OpenReader(bytes.NewReader(encode(init))); err != nil {
+				t.Fatalf("OpenReader failed: %v", err)
+			}
+			defer dec.Close()
+
+			if got := dec.Tags().Get("ALBUM"); got != "Stream" {
+				t.Errorf("ALBUM = %q, want Stream", got)
+			}
+			if pics := dec.Pictures(); len(pics) != 1 || !equalPicture(pics[0], cover) {
+				t.Errorf("Pictures() = %+v, want %+v", pics, cover)
+			}
+		})
+	}
+}
+
+func TestFlacEncoder_MetadataValidation(t *testing.T) {
+	enc, err := NewFlacEncoder(44100, 2, 16)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.SetTags(&Tags{Fields: []TagField{{"BAD=NAME", "x"}}}); err == nil {
+		t.Error("SetTags accepted an invalid field name")
+	}
+	if err := enc.AddPicture(&Picture{Type: PicturePublisherLogo + 1}); err == nil {
+		t.Error("AddPicture accepted an invalid picture type")
+	}
+	if err := enc.AddPicture(nil); err == nil {
+		t.Error("AddPicture accepted nil")
+	}
+	if err := enc.AddApplication([4]byte{}, make([]byte, maxMetadataBlockLength)); err == nil {
+		t.Error("AddApplication accepted an oversized block")
+	}
+	for _, n := range []int{-1, maxMetadataBlockLength + 1} {
+		if err := enc.SetPadding(n); err == nil {
+			t.Errorf("SetPadding(%d) succeeded", n)
+		}
+	}
+}
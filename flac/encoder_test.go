@@ -1,8 +1,12 @@
 package flac
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -42,7 +46,8 @@ func TestNewFlacEncoder_InvalidParams(t *testing.T) {
 		{-1, 2, 16, "negative sample rate"},
 		{44100, 0, 16, "zero channels"},
 		{44100, 9, 16, "too many channels"},
-		{44100, 2, 12, "invalid bit depth"},
+		{44100, 2, 3, "bit depth below 4"},
+		{44100, 2, 33, "bit depth above 32"},
 		{44100, 2, 0, "zero bit depth"},
 	}
 
@@ -677,3 +682,244 @@ func TestPCMToInt32_32bit(t *testing.T) {
 		}
 	}
 }
+
+func TestFlacEncoder_InitOggFileAndDecode(t *testing.T) {
+	if !OggSupported() {
+		t.Skip("libFLAC built without Ogg support")
+	}
+
+	outFile := filepath.Join(t.TempDir(), "test.oga")
+	numSamples := 8192
+	origSamples := generateTestSignal(numSamples, 2, 16)
+
+	enc, err := NewFlacEncoder(44100, 2, 16)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.SetOggSerialNumber(1234); err != nil {
+		t.Fatalf("SetOggSerialNumber failed: %v", err)
+	}
+	if err := enc.InitOggFile(outFile); err != nil {
+		t.Fatalf("InitOggFile failed: %v", err)
+	}
+	if err := enc.ProcessInterleaved(origSamples, numSamples); err != nil {
+		t.Fatalf("ProcessInterleaved failed: %v", err)
+	}
+	if err := enc.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !isOggHeader(data) {
+		t.Fatalf("Output does not start with an Ogg page: %q", data[:4])
+	}
+	// Bytes 14-17 of the first page header hold the serial number (LE)
+	if serial := binary.LittleEndian.Uint32(data[14:18]); serial != 1234 {
+		t.Errorf("Ogg serial number = %d, want 1234", serial)
+	}
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.Open(outFile); err != nil {
+		t.Fatalf("Failed to open Ogg FLAC: %v", err)
+	}
+	defer dec.Close()
+
+	if !dec.IsOgg() {
+		t.Error("IsOgg should report true for an Ogg FLAC file")
+	}
+	if dec.TotalSamples() != int64(numSamples) {
+		t.Errorf("TotalSamples = %d, want %d", dec.TotalSamples(), numSamples)
+	}
+
+	pcm := decodeAllBytes(t, dec)
+	decoded := make([]int32, len(pcm)/2)
+	PCMToInt32(pcm, 16, decoded)
+	if len(decoded) != len(origSamples) {
+		t.Fatalf("Decoded %d values, want %d", len(decoded), len(origSamples))
+	}
+	for i := range decoded {
+		if decoded[i] != origSamples[i] {
+			t.Fatalf("Sample %d mismatch: got %d, want %d", i, decoded[i], origSamples[i])
+		}
+	}
+
+	// Seeking works on Ogg FLAC files as well
+	if _, err := dec.Seek(int64(numSamples/2), io.SeekStart); err != nil {
+		t.Errorf("Seek in Ogg FLAC failed: %v", err)
+	}
+}
+
+func TestFlacEncoder_InitOggStreamAndDecode(t *testing.T) {
+	if !OggSupported() {
+		t.Skip("libFLAC built without Ogg support")
+	}
+
+	numSamples := 4096
+	origSamples := generateTestSignal(numSamples, 1, 16)
+
+	enc, err := NewFlacEncoder(48000, 1, 16)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.SetTotalSamplesEstimate(int64(numSamples)); err != nil {
+		t.Fatalf("SetTotalSamplesEstimate failed: %v", err)
+	}
+	if err := enc.InitOggStream(); err != nil {
+		t.Fatalf("InitOggStream failed: %v", err)
+	}
+	if err := enc.ProcessInterleaved(origSamples, numSamples); err != nil {
+		t.Fatalf("ProcessInterleaved failed: %v", err)
+	}
+	if err := enc.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	data := enc.TakeBytes()
+	if !isOggHeader(data) {
+		t.Fatal("InitOggStream output does not start with an Ogg page")
+	}
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.OpenReader(bytes.NewReader(data)); err != nil {
+		t.Fatalf("OpenReader on Ogg stream failed: %v", err)
+	}
+	defer dec.Close()
+
+	if !dec.IsOgg() {
+		t.Error("IsOgg should report true for an Ogg FLAC stream")
+	}
+
+	pcm := decodeAllBytes(t, dec)
+	decoded := make([]int32, len(pcm)/2)
+	PCMToInt32(pcm, 16, decoded)
+	if len(decoded) != numSamples {
+		t.Fatalf("Decoded %d samples, want %d", len(decoded), numSamples)
+	}
+	for i := range decoded {
+		if decoded[i] != origSamples[i] {
+			t.Fatalf("Sample %d mismatch: got %d, want %d", i, decoded[i], origSamples[i])
+		}
+	}
+}
+
+func TestFlacEncoder_SetOggSerialNumberAfterInit(t *testing.T) {
+	enc, err := NewFlacEncoder(44100, 2, 16)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.InitStream(); err != nil {
+		t.Fatalf("InitStream failed: %v", err)
+	}
+	if err := enc.SetOggSerialNumber(1); err == nil {
+		t.Error("SetOggSerialNumber after init should fail")
+	}
+	enc.Finish()
+}
+
+func TestPCMToInt32_12bit(t *testing.T) {
+	expected := []int32{0, 2047, -2048, -1}
+
+	// Left-justified 12-bit samples stored in 16-bit words (the default)
+	pcm := []byte{
+		0x00, 0x00, // 0
+		0xF0, 0x7F, // 2047
+		0x00, 0x80, // -2048
+		0xF0, 0xFF, // -1
+	}
+	out := make([]int32, 4)
+	if n := PCMToInt32(pcm, 12, out); n != 4 {
+		t.Fatalf("Expected 4 samples, got %d", n)
+	}
+	for i, want := range expected {
+		if out[i] != want {
+			t.Errorf("left-justified sample[%d] = %d, want %d", i, out[i], want)
+		}
+	}
+
+	// Right-justified 12-bit samples stored in 16-bit words
+	pcm = []byte{
+		0x00, 0x00, // 0
+		0xFF, 0x07, // 2047
+		0x00, 0xF8, // -2048
+		0xFF, 0xFF, // -1
+	}
+	if n := PCMToInt32Justified(pcm, 12, RightJustified, out); n != 4 {
+		t.Fatalf("Expected 4 samples, got %d", n)
+	}
+	for i, want := range expected {
+		if out[i] != want {
+			t.Errorf("right-justified sample[%d] = %d, want %d", i, out[i], want)
+		}
+	}
+}
+
+func TestPCMToInt32_20bit(t *testing.T) {
+	// 20-bit full scale left-justified in 24-bit containers
+	pcm := []byte{0xF0, 0xFF, 0x7F, 0x00, 0x00, 0x80}
+	out := make([]int32, 2)
+	if n := PCMToInt32(pcm, 20, out); n != 2 {
+		t.Fatalf("Expected 2 samples, got %d", n)
+	}
+	if out[0] != 524287 || out[1] != -524288 {
+		t.Errorf("samples = %v, want [524287 -524288]", out)
+	}
+}
+
+func TestFlacEncoder_ReencodeDecoded12bit(t *testing.T) {
+	// Decoder output goes straight back into the encoder: with PCMToInt32
+	// at the default justification, or with the matching one otherwise.
+	for _, j := range []SampleJustification{LeftJustified, RightJustified} {
+		t.Run(j.String(), func(t *testing.T) {
+			const numSamples = 5000
+			path, orig := encodeTestFile(t, 44100, 2, 12, numSamples)
+
+			dec, err := NewFlacFrameDecoder(16)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.SetSampleJustification(j); err != nil {
+				t.Fatalf("SetSampleJustification failed: %v", err)
+			}
+			if err := dec.Open(path); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			pcm := decodeAllBytes(t, dec)
+			dec.Close()
+
+			samples := make([]int32, len(pcm)/2)
+			if j == LeftJustified {
+				PCMToInt32(pcm, 12, samples)
+			} else {
+				PCMToInt32Justified(pcm, 12, j, samples)
+			}
+			reencoded := encodeSamplesFile(t, 44100, 2, 12, samples)
+
+			decoded, _ := decodeInt32All(t, reencoded, func(d *FlacDecoder) error {
+				return d.SetSampleJustification(RightJustified)
+			})
+			if !slices.Equal(decoded, orig) {
+				t.Error("re-encoded 12-bit samples differ from the original")
+			}
+		})
+	}
+}
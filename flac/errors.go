@@ -0,0 +1,156 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/stream_decoder.h>
+#include <FLAC/stream_encoder.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Sentinel errors for use with errors.Is. Decoder errors reported by
+// libFLAC (*StreamError) match ErrLostSync, ErrBadHeader, ErrCRCMismatch
+// and ErrUnparseableStream by status; an *EncoderError matches
+// ErrVerifyMismatch when the encoder's verification failed.
+var (
+	ErrLostSync          = errors.New("lost sync")
+	ErrBadHeader         = errors.New("bad header")
+	ErrCRCMismatch       = errors.New("frame CRC mismatch")
+	ErrUnparseableStream = errors.New("unparseable stream")
+	ErrMD5Mismatch       = errors.New("MD5 mismatch")
+	ErrVerifyMismatch    = errors.New("verify mismatch in audio data")
+)
+
+// DecoderState is the state of libFLAC's stream decoder
+// (FLAC__StreamDecoderState).
+type DecoderState int
+
+func (s DecoderState) String() string {
+	return cString(unsafe.Pointer(&C.FLAC__StreamDecoderStateString),
+		int(C.FLAC__STREAM_DECODER_UNINITIALIZED)+1, int(s), "DecoderState")
+}
+
+// DecoderInitStatus is the result of initializing libFLAC's stream decoder
+// (FLAC__StreamDecoderInitStatus).
+type DecoderInitStatus int
+
+func (s DecoderInitStatus) String() string {
+	return cString(unsafe.Pointer(&C.FLAC__StreamDecoderInitStatusString),
+		int(C.FLAC__STREAM_DECODER_INIT_STATUS_ALREADY_INITIALIZED)+1, int(s), "DecoderInitStatus")
+}
+
+// EncoderState is the state of libFLAC's stream encoder
+// (FLAC__StreamEncoderState).
+type EncoderState int
+
+func (s EncoderState) String() string {
+	return cString(unsafe.Pointer(&C.FLAC__StreamEncoderStateString),
+		int(C.FLAC__STREAM_ENCODER_MEMORY_ALLOCATION_ERROR)+1, int(s), "EncoderState")
+}
+
+// EncoderInitStatus is the result of initializing libFLAC's stream encoder
+// (FLAC__StreamEncoderInitStatus).
+type EncoderInitStatus int
+
+func (s EncoderInitStatus) String() string {
+	return cString(unsafe.Pointer(&C.FLAC__StreamEncoderInitStatusString),
+		int(C.FLAC__STREAM_ENCODER_INIT_STATUS_ALREADY_INITIALIZED)+1, int(s), "EncoderInitStatus")
+}
+
+// cString returns entry idx of one of libFLAC's string tables, which has
+// length entries.
+func cString(table unsafe.Pointer, length, idx int, typeName string) string {
+	if idx < 0 || idx >= length {
+		return fmt.Sprintf("%s(%d)", typeName, idx)
+	}
+	return C.GoString(unsafe.Slice((**C.char)(table), length)[idx])
+}
+
+// DecoderError is a failure of libFLAC's stream decoder. Status is set when
+// the decoder could not be initialized for a stream, State otherwise.
+type DecoderError struct {
+	Op     string // operation that failed, e.g. "decode samples"
+	State  DecoderState
+	Status DecoderInitStatus
+}
+
+func (e *DecoderError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s error: %s", e.Op, e.Status)
+	}
+	return fmt.Sprintf("%s error: %s", e.Op, e.State)
+}
+
+// decoderError returns a *DecoderError for op with the decoder's current
+// state.
+func (d *FlacDecoder) decoderError(op string) error {
+	return &DecoderError{Op: op, State: DecoderState(C.FLAC__stream_decoder_get_state(d.decoder))}
+}
+
+// VerifyStats locates the first sample where the encoder's verification
+// decoder disagreed with the input.
+type VerifyStats struct {
+	AbsoluteSample uint64 // sample position in the stream
+	FrameNumber    uint32
+	Channel        uint32
+	Sample         uint32 // sample position within the frame
+	Expected       int32  // input sample
+	Got            int32  // decoded sample
+}
+
+// EncoderError is a failure of libFLAC's stream encoder. Status is set
+// when the encoder could not be initialized, State otherwise. VerifyStats
+// is set when State is a verification mismatch.
+type EncoderError struct {
+	Op          string // operation that failed, e.g. "process interleaved"
+	State       EncoderState
+	Status      EncoderInitStatus
+	VerifyStats *VerifyStats
+}
+
+func (e *EncoderError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s error: %s", e.Op, e.Status)
+	}
+	if v := e.VerifyStats; v != nil {
+		return fmt.Sprintf("%s error: %s at sample %d (frame %d, channel %d): expected %d, got %d",
+			e.Op, e.State, v.AbsoluteSample, v.FrameNumber, v.Channel, v.Expected, v.Got)
+	}
+	return fmt.Sprintf("%s error: %s", e.Op, e.State)
+}
+
+// Is reports whether target is ErrVerifyMismatch and the encoder's
+// verification failed.
+func (e *EncoderError) Is(target error) bool {
+	return target == ErrVerifyMismatch && e.State == C.FLAC__STREAM_ENCODER_VERIFY_MISMATCH_IN_AUDIO_DATA
+}
+
+// encoderError returns an *EncoderError for op with the encoder's current
+// state, including the verification details on a mismatch.
+func (e *FlacEncoder) encoderError(op string) error {
+	err := &EncoderError{Op: op, State: EncoderState(C.FLAC__stream_encoder_get_state(e.encoder))}
+	if err.State == C.FLAC__STREAM_ENCODER_VERIFY_MISMATCH_IN_AUDIO_DATA {
+		var (
+			absoluteSample C.FLAC__uint64
+			frame, channel C.uint32_t
+			sample         C.uint32_t
+			expected, got  C.FLAC__int32
+		)
+		C.FLAC__stream_encoder_get_verify_decoder_error_stats(e.encoder,
+			&absoluteSample, &frame, &channel, &sample, &expected, &got)
+		err.VerifyStats = &VerifyStats{
+			AbsoluteSample: uint64(absoluteSample),
+			FrameNumber:    uint32(frame),
+			Channel:        uint32(channel),
+			Sample:         uint32(sample),
+			Expected:       int32(expected),
+			Got:            int32(got),
+		}
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDecoderError_OpenNonExistent(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	err = dec.Open("/nonexistent/file.flac")
+	var decErr *DecoderError
+	if !errors.As(err, &decErr) {
+		t.Fatalf("Open error = %v, want *DecoderError", err)
+	}
+	if got, want := decErr.Status.String(), "FLAC__STREAM_DECODER_INIT_STATUS_ERROR_OPENING_FILE"; got != want {
+		t.Errorf("Status = %q, want %q", got, want)
+	}
+	if got, want := err.Error(), "init flac error: FLAC__STREAM_DECODER_INIT_STATUS_ERROR_OPENING_FILE"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorStateStrings(t *testing.T) {
+	tests := []struct {
+		s    fmt.Stringer
+		want string
+	}{
+		{DecoderState(0), "FLAC__STREAM_DECODER_SEARCH_FOR_METADATA"},
+		{DecoderState(4), "FLAC__STREAM_DECODER_END_OF_STREAM"},
+		{DecoderState(9), "FLAC__STREAM_DECODER_UNINITIALIZED"},
+		{DecoderInitStatus(5), "FLAC__STREAM_DECODER_INIT_STATUS_ALREADY_INITIALIZED"},
+		{EncoderState(4), "FLAC__STREAM_ENCODER_VERIFY_MISMATCH_IN_AUDIO_DATA"},
+		{EncoderState(8), "FLAC__STREAM_ENCODER_MEMORY_ALLOCATION_ERROR"},
+		{EncoderInitStatus(13), "FLAC__STREAM_ENCODER_INIT_STATUS_ALREADY_INITIALIZED"},
+	}
+	for _, tt := range tests {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestErrorStateStrings_Unit(t *testing.T) {
+	tests := []struct {
+		s    fmt.Stringer
+		want string
+	}{
+		{DecoderState(-1), "DecoderState(-1)"},
+		{DecoderState(10), "DecoderState(10)"},
+		{DecoderInitStatus(6), "DecoderInitStatus(6)"},
+		{EncoderState(9), "EncoderState(9)"},
+		{EncoderInitStatus(14), "EncoderInitStatus(14)"},
+	}
+	for _, tt := range tests {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestStreamErrorIs_Unit(t *testing.T) {
+	tests := []struct {
+		status ErrorStatus
+		want   error
+	}{
+		{ErrorLostSync, ErrLostSync},
+		{ErrorBadHeader, ErrBadHeader},
+		{ErrorFrameCRCMismatch, ErrCRCMismatch},
+		{ErrorUnparseableStream, ErrUnparseableStream},
+	}
+	sentinels := []error{ErrLostSync, ErrBadHeader, ErrCRCMismatch, ErrUnparseableStream, ErrMD5Mismatch}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("decode: %w", &StreamError{Status: tt.status, Sample: 100})
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("errors.Is(%v, %v) = %v", err, sentinel, got)
+			}
+		}
+	}
+
+	if errors.Is(&StreamError{Status: ErrorMissingFrame}, ErrLostSync) {
+		t.Error("missing frame matches ErrLostSync")
+	}
+}
+
+func TestEncoderErrorIs_Unit(t *testing.T) {
+	stats := &VerifyStats{AbsoluteSample: 5000, FrameNumber: 1, Channel: 1, Sample: 904, Expected: 7, Got: 8}
+	err := &EncoderError{Op: "process interleaved", State: 4, VerifyStats: stats}
+	if !errors.Is(err, ErrVerifyMismatch) {
+		t.Error("verify mismatch does not match ErrVerifyMismatch")
+	}
+	if errors.Is(&EncoderError{Op: "finish", State: 6}, ErrVerifyMismatch) {
+		t.Error("I/O error matches ErrVerifyMismatch")
+	}
+
+	var encErr *EncoderError
+	if !errors.As(fmt.Errorf("encode: %w", err), &encErr) || encErr.VerifyStats.AbsoluteSample != 5000 {
+		t.Error("errors.As did not find the verify details")
+	}
+}
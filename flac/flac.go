@@ -2,6 +2,7 @@ package flac
 
 /*
 #cgo pkg-config: flac
+#include <FLAC/export.h>
 #include <FLAC/format.h>
 #include <FLAC/stream_decoder.h>
 #include <stdlib.h>
@@ -19,6 +20,9 @@ get_decoder_rate(FLAC__StreamMetadata *metadata);
 extern FLAC__uint64
 get_total_samples(FLAC__StreamMetadata *metadata);
 
+extern unsigned int
+get_max_blocksize(FLAC__StreamMetadata *metadata);
+
 extern void
 decoderErrorCallback_cgo(const FLAC__StreamDecoder *,
                  FLAC__StreamDecoderErrorStatus,
@@ -49,15 +53,32 @@ decoder_init_file_handle(FLAC__StreamDecoder *decoder,
     return FLAC__stream_decoder_init_file(
         decoder, filename, write_cb, metadata_cb, error_cb, (void *)handle);
 }
+
+// decoder_init_ogg_file_handle is the Ogg FLAC counterpart of
+// decoder_init_file_handle.
+static inline FLAC__StreamDecoderInitStatus
+decoder_init_ogg_file_handle(FLAC__StreamDecoder *decoder,
+                             const char *filename,
+                             FLAC__StreamDecoderWriteCallback write_cb,
+                             FLAC__StreamDecoderMetadataCallback metadata_cb,
+                             FLAC__StreamDecoderErrorCallback error_cb,
+                             uintptr_t handle)
+{
+    return FLAC__stream_decoder_init_ogg_file(
+        decoder, filename, write_cb, metadata_cb, error_cb, (void *)handle);
+}
 */
 import "C"
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
 	"runtime/cgo"
+	"time"
 	"unsafe"
 
 	"github.com/drgolem/ringbuffer"
@@ -81,6 +102,42 @@ func GetVersion() string {
 	return C.GoString(C.FLAC__VERSION_STRING)
 }
 
+// ErrOggNotSupported is returned when an Ogg FLAC stream is opened (or an
+// Ogg encoder is initialized) but the linked libFLAC was built without
+// Ogg support.
+var ErrOggNotSupported = errors.New("libFLAC was built without Ogg FLAC support")
+
+// OggSupported reports whether the linked libFLAC can read and write
+// Ogg FLAC streams.
+func OggSupported() bool {
+	return C.FLAC__API_SUPPORTS_OGG_FLAC != 0
+}
+
+// oggMagic is the capture pattern that starts every Ogg page.
+var oggMagic = []byte("OggS")
+
+// isOggHeader reports whether the first bytes of a stream belong to an
+// Ogg container rather than native FLAC.
+func isOggHeader(header []byte) bool {
+	return bytes.HasPrefix(header, oggMagic)
+}
+
+// sniffOggFile reports whether the file at filePath is an Ogg container.
+// Errors are ignored here; libFLAC reports them when opening the file.
+func sniffOggFile(filePath string) bool {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(oggMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return isOggHeader(header)
+}
+
 // FlacDecoder provides thread-safe FLAC audio decoding using a lock-free
 // SPSC (Single Producer Single Consumer) ring buffer.
 //
@@ -94,6 +151,10 @@ func GetVersion() string {
 // IMPORTANT: The decoder itself should still be used from a single goroutine for
 // methods like DecodeSamples(), Seek(), Open(), and Close(). The thread-safety
 // applies to the internal buffer operations between CGO callbacks and Go code.
+//
+// By default libFLAC runs inside DecodeSamples. With SetReadAhead it runs on
+// a dedicated goroutine that keeps the ring buffer filled, and DecodeSamples
+// only drains it.
 type FlacDecoder struct {
 	decoder  *C.FLAC__StreamDecoder
 	hDecoder cgo.Handle
@@ -103,33 +164,151 @@ type FlacDecoder struct {
 	bitsPerSample           int
 	outputBytesPerSample    int
 	currentSample           int64
-	totalSamples            int64
+	totalSamples            int64 // in output samples, see configureResampler
+	streamTotalSamples      int64 // STREAMINFO total, in stream samples
 	maxOutputSampleBitDepth int
 	streamBytesPerSample    int
 
-	// Lock-free SPSC ring buffer for thread-safe audio data transfer
-	ringBuffer *ringbuffer.RingBuffer
-	b16        [2]byte
-	b24        [3]byte
+	// outputBits is the number of significant bits in each output sample;
+	// outputBytesPerSample is the container size they are packed into.
+	outputBits    int
+	justification SampleJustification
+
+	// Bit-depth reduction when outputBits < bitsPerSample
+	requant requantizer
+
+	// frameCapture receives the next frame while DecodeFrame is running;
+	// seekFrame holds the frame libFLAC delivered during the last Seek.
+	frameCapture **Frame
+	seekFrame    *Frame
+	seeking      bool
+
+	// analysis adds subframe details to decoded frames
+	analysis bool
+
+	// streamChannels is the channel count of the stream; channels is the
+	// output channel count after channelMap or mixMatrix is applied.
+	streamChannels int
+	channelMap     []int
+	mixMatrix      [][]float64
+
+	// Sample rate conversion (see SetOutputSampleRate); resampler is nil
+	// when the stream is decoded at its own rate.
+	outputRate      int
+	resampleQuality ResampleQuality
+	resampler       *resampler
+
+	maxBlocksize int
+
+	// MD5 verification against STREAMINFO (see SetVerifyMD5)
+	verifyMD5 bool
+	md5Status MD5Status
+	md5       md5Verifier
+
+	// Handling of damaged streams (see SetErrorPolicy). nextFrameSample is
+	// the stream sample the next frame should start at; badFrameSample is
+	// where a frame that failed its CRC check starts, or -1.
+	errorPolicy     ErrorPolicy
+	streamErrors    streamErrors
+	nextFrameSample int64
+	badFrameSample  int64
+
+	// Background decoding (see SetReadAhead); readAhead is nil unless the
+	// goroutine is running.
+	readAheadLatency time.Duration
+	readAhead        *readAhead
+
+	// Scratch for one frame: libFLAC's channel buffers, the interleaved
+	// output samples and their packed bytes
+	chanBuf [][]int32
+	mixBuf  []int32
+	packBuf []byte
+
+	// Lock-free SPSC ring buffer for thread-safe audio data transfer.
+	// ringCapacity is the size requested at construction, 0 for automatic
+	// sizing from STREAMINFO.
+	ringBuffer   *ringbuffer.RingBuffer
+	ringCapacity int
+
+	// Metadata blocks other than STREAMINFO, read during Open
+	tags      *Tags
+	pictures  []*Picture
+	seekTable []SeekPoint
 
 	// Error state from decoder callbacks
 	lastError error
+
+	// Source for stream mode (OpenReader, OpenReadSeeker); nil when
+	// decoding a file. seeker is set only for seekable sources.
+	reader    io.Reader
+	seeker    io.Seeker
+	readerEOF bool
+
+	// ogg is set when the current stream uses the Ogg FLAC container
+	ogg bool
+
+	// Scratch buffers reused by the typed Decode* variants
+	pcmBuf   []byte
+	int32Buf []int32
+
+	// directOut is the rest of the caller's slice while DecodeInt32
+	// decodes a frame: writeSamples fills it before using the ring buffer.
+	directOut []int32
 }
 
 const (
 	ringBufferCapacity = 2 * 2 * 4 * 4096
 
+	// Upper limit for an explicitly requested ring buffer size
+	maxRingBufferCapacity = 1 << 30
+
 	// Valid bit depths for FLAC audio
 	bitDepth8  = 8
 	bitDepth16 = 16
 	bitDepth24 = 24
 	bitDepth32 = 32
+
+	// Range of bit depths allowed by the FLAC format
+	minBitDepth = 4
+	maxBitDepth = 32
+
+	// Largest block size the FLAC format allows, assumed when STREAMINFO
+	// leaves it unset
+	maxFrameBlocksize = 65535
+)
+
+// SampleJustification selects how samples whose bit depth is not a
+// multiple of 8 (12-bit, 20-bit, ...) are placed in their output container.
+type SampleJustification int
+
+const (
+	// LeftJustified scales samples to fill the container, so a 12-bit
+	// sample occupies the top 12 bits of a 16-bit word and full scale is
+	// the same as for native 16-bit audio. This is the WAV convention and
+	// the default.
+	LeftJustified SampleJustification = iota
+
+	// RightJustified keeps samples at their native magnitude, sign-extended
+	// into the container. A 12-bit sample stays in [-2048, 2047].
+	RightJustified
 )
 
+func (j SampleJustification) String() string {
+	switch j {
+	case LeftJustified:
+		return "left-justified"
+	case RightJustified:
+		return "right-justified"
+	default:
+		return fmt.Sprintf("SampleJustification(%d)", int(j))
+	}
+}
+
 // NewFlacFrameDecoder creates a new thread-safe FLAC decoder.
 //
 // Parameters:
-//   - maxOutputSampleBitDepth: Output bit depth (8, 16, 24, or 32)
+//   - maxOutputSampleBitDepth: Output bit depth (8, 16, 24, or 32). Streams
+//     with a higher depth are reduced as selected by SetRequantizeMode.
 //
 // Returns the decoder instance or an error if the bit depth is unsupported.
 //
@@ -137,16 +316,37 @@ const (
 // libFLAC's C callbacks and Go code, providing better performance than mutex-based
 // approaches while maintaining safety.
 func NewFlacFrameDecoder(maxOutputSampleBitDepth int) (*FlacDecoder, error) {
+	return NewFlacFrameDecoderWithCapacity(maxOutputSampleBitDepth, 0)
+}
+
+// NewFlacFrameDecoderWithCapacity creates a decoder with an explicit ring
+// buffer size in bytes, rounded up to a power of two.
+//
+// By default (capacity 0) the ring buffer is sized when a stream is opened
+// to hold two of the largest frames STREAMINFO announces. An explicit
+// capacity is used as is, which keeps memory low for streams with small
+// frames; the buffer still grows when a decoded frame does not fit.
+func NewFlacFrameDecoderWithCapacity(maxOutputSampleBitDepth int, capacity int) (*FlacDecoder, error) {
 	// Validate bit depth
 	if maxOutputSampleBitDepth != bitDepth8 && maxOutputSampleBitDepth != bitDepth16 &&
 		maxOutputSampleBitDepth != bitDepth24 && maxOutputSampleBitDepth != bitDepth32 {
 		return nil, fmt.Errorf("invalid maxOutputSampleBitDepth: %d, must be 8, 16, 24, or 32", maxOutputSampleBitDepth)
 	}
+	if capacity < 0 || capacity > maxRingBufferCapacity {
+		return nil, fmt.Errorf("invalid ring buffer capacity: %d, must be 0-%d", capacity, maxRingBufferCapacity)
+	}
+
+	size := ringBufferCapacity
+	if capacity > 0 {
+		size = capacity
+	}
 
 	dec := &FlacDecoder{
 		maxOutputSampleBitDepth: maxOutputSampleBitDepth,
 		outputBytesPerSample:    maxOutputSampleBitDepth / 8,
-		ringBuffer:              ringbuffer.New(ringBufferCapacity),
+		outputBits:              maxOutputSampleBitDepth,
+		ringBuffer:              ringbuffer.New(uint64(size)),
+		ringCapacity:            capacity,
 	}
 
 	dec.decoder = C.FLAC__stream_decoder_new()
@@ -163,6 +363,7 @@ func NewFlacFrameDecoder(maxOutputSampleBitDepth int) (*FlacDecoder, error) {
 // Delete cleans up the decoder and frees resources.
 // Must be called when done with the decoder to prevent memory leaks.
 func (d *FlacDecoder) Delete() error {
+	d.stopReadAhead()
 	if d.decoder != nil {
 		C.FLAC__stream_decoder_delete(d.decoder)
 		d.decoder = nil
@@ -174,6 +375,18 @@ func (d *FlacDecoder) Delete() error {
 	return nil
 }
 
+// SetSampleJustification selects how samples of a non byte-aligned bit
+// depth are placed in their output container. The default is
+// LeftJustified. Call it before Open; changing it mid-stream affects only
+// frames decoded afterwards.
+func (d *FlacDecoder) SetSampleJustification(j SampleJustification) error {
+	if j != LeftJustified && j != RightJustified {
+		return fmt.Errorf("invalid sample justification: %d", int(j))
+	}
+	d.justification = j
+	return nil
+}
+
 // GetResolvedState returns the current decoder state as a string.
 func (d *FlacDecoder) GetResolvedState() string {
 	if d.decoder == nil {
@@ -183,6 +396,10 @@ func (d *FlacDecoder) GetResolvedState() string {
 }
 
 // Open opens a FLAC file for decoding.
+//
+// Both native FLAC and Ogg FLAC (.oga/.ogg) files are accepted; the
+// container is detected from the first bytes of the file. Opening an Ogg
+// file fails with ErrOggNotSupported if libFLAC lacks Ogg support.
 func (d *FlacDecoder) Open(filePath string) error {
 	if d.decoder == nil {
 		return errors.New("decoder not initialized")
@@ -195,56 +412,156 @@ func (d *FlacDecoder) Open(filePath string) error {
 	metadataCallback := C.FLAC__StreamDecoderMetadataCallback(unsafe.Pointer(C.decoderMetadataCallback_cgo))
 	errorCallback := C.FLAC__StreamDecoderErrorCallback(unsafe.Pointer(C.decoderErrorCallback_cgo))
 
-	// Reset decoder state
+	d.resetState()
+
+	if sniffOggFile(filePath) {
+		if !OggSupported() {
+			return ErrOggNotSupported
+		}
+		d.ogg = true
+	}
+
+	// Pass the handle as uintptr_t via C helper to avoid creating an
+	// unsafe.Pointer from a cgo.Handle (which is a uintptr, not a real pointer).
+	// The C helper casts uintptr_t → void* for libFLAC's client_data parameter.
+	var status C.FLAC__StreamDecoderInitStatus
+	if d.ogg {
+		status = C.decoder_init_ogg_file_handle(d.decoder, filename,
+			writeCallback,
+			metadataCallback,
+			errorCallback,
+			C.uintptr_t(d.hDecoder),
+		)
+	} else {
+		status = C.decoder_init_file_handle(d.decoder, filename,
+			writeCallback,
+			metadataCallback,
+			errorCallback,
+			C.uintptr_t(d.hDecoder),
+		)
+	}
+
+	return d.completeOpen(status)
+}
+
+// resetState clears per-stream state before a new stream is opened.
+func (d *FlacDecoder) resetState() {
+	d.stopReadAhead()
+
+	// libFLAC forgets the metadata filter when it finishes a stream, so
+	// ask for the blocks read besides STREAMINFO before every init.
+	C.FLAC__stream_decoder_set_metadata_respond(d.decoder, C.FLAC__METADATA_TYPE_VORBIS_COMMENT)
+	C.FLAC__stream_decoder_set_metadata_respond(d.decoder, C.FLAC__METADATA_TYPE_PICTURE)
+	C.FLAC__stream_decoder_set_metadata_respond(d.decoder, C.FLAC__METADATA_TYPE_SEEKTABLE)
+
 	d.rate = 0
 	d.channels = 0
+	d.streamChannels = 0
 	d.bitsPerSample = 0
 	d.outputBytesPerSample = d.maxOutputSampleBitDepth / 8
+	d.outputBits = d.maxOutputSampleBitDepth
 	d.currentSample = 0
 	d.totalSamples = 0
+	d.streamTotalSamples = 0
 	d.lastError = nil
+	d.reader = nil
+	d.seeker = nil
+	d.readerEOF = false
+	d.ogg = false
+	d.seekFrame = nil
+	d.resampler = nil
+	d.maxBlocksize = 0
+	d.resetMD5()
+	d.streamErrors.reset()
+	d.nextFrameSample = 0
+	d.badFrameSample = -1
+	d.tags = nil
+	d.pictures = nil
+	d.seekTable = nil
 	d.ringBuffer.Reset()
+}
 
-	// Pass the handle as uintptr_t via C helper to avoid creating an
-	// unsafe.Pointer from a cgo.Handle (which is a uintptr, not a real pointer).
-	// The C helper casts uintptr_t → void* for libFLAC's client_data parameter.
-	status := C.decoder_init_file_handle(d.decoder, filename,
-		writeCallback,
-		metadataCallback,
-		errorCallback,
-		C.uintptr_t(d.hDecoder),
-	)
-
+// completeOpen checks the init status and reads the stream metadata.
+// Shared by all Open* entry points.
+func (d *FlacDecoder) completeOpen(status C.FLAC__StreamDecoderInitStatus) error {
+	if status == C.FLAC__STREAM_DECODER_INIT_STATUS_UNSUPPORTED_CONTAINER {
+		return ErrOggNotSupported
+	}
 	if status != C.FLAC__STREAM_DECODER_INIT_STATUS_OK {
-		errStr := getStreamDecoderInitStatusString(status)
-		return fmt.Errorf("init flac error: %s", errStr)
+		return &DecoderError{Op: "init flac", State: DecoderState(C.FLAC__stream_decoder_get_state(d.decoder)), Status: DecoderInitStatus(status)}
 	}
 
 	if C.FLAC__stream_decoder_process_until_end_of_metadata(d.decoder) == 0 {
-		state := C.FLAC__stream_decoder_get_state(d.decoder)
-		return fmt.Errorf("decode metadata error: %d", state)
+		if d.lastError != nil {
+			err := d.lastError
+			d.lastError = nil
+			return err
+		}
+		return d.decoderError("decode metadata")
 	}
 
+	if d.channels == 0 {
+		return errors.New("decode metadata error: no STREAMINFO block found")
+	}
+	if d.lastError != nil {
+		err := d.lastError
+		d.lastError = nil
+		return err
+	}
+
+	if d.ringCapacity == 0 {
+		d.reserveRing(2 * d.maxFrameSamples() * d.frameBytes())
+	}
+	d.startReadAhead()
 	return nil
 }
 
-// Close closes the decoder.
+// Close closes the decoder. With SetVerifyMD5, it returns an
+// *MD5MismatchError if the whole stream was decoded, the audio did not
+// match and the mismatch has not been returned by DecodeSamples yet.
 func (d *FlacDecoder) Close() error {
+	d.stopReadAhead()
+	var err error
 	if d.decoder != nil {
+		if C.FLAC__stream_decoder_get_state(d.decoder) == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+			err = d.checkMD5()
+		}
 		C.FLAC__stream_decoder_finish(d.decoder)
 	}
 
 	// Reset decoder state
 	d.rate = 0
 	d.channels = 0
+	d.streamChannels = 0
 	d.bitsPerSample = 0
 	d.outputBytesPerSample = 0
+	d.outputBits = 0
 	d.currentSample = 0
 	d.totalSamples = 0
+	d.streamTotalSamples = 0
 	d.lastError = nil
+	d.reader = nil
+	d.seeker = nil
+	d.readerEOF = false
+	d.ogg = false
+	d.seekFrame = nil
+	d.resampler = nil
+	d.maxBlocksize = 0
+	d.resetMD5()
+	d.streamErrors.reset()
+	d.nextFrameSample = 0
+	d.badFrameSample = -1
+	d.tags = nil
+	d.pictures = nil
+	d.seekTable = nil
 	d.ringBuffer.Reset()
 
-	return nil
+	return err
+}
+
+// IsOgg reports whether the open stream is Ogg FLAC rather than native FLAC.
+func (d *FlacDecoder) IsOgg() bool {
+	return d.ogg
 }
 
 // TotalSamples returns the total number of samples in the stream.
@@ -254,16 +571,40 @@ func (d *FlacDecoder) TotalSamples() int64 {
 
 // TellCurrentSample returns the current sample position.
 func (d *FlacDecoder) TellCurrentSample() int64 {
+	defer d.lockState()()
 	return d.currentSample
 }
 
 // GetFormat returns the audio format parameters.
-// The returned bitsPerSample reflects the effective output bit depth,
-// which is min(file native depth, maxOutputSampleBitDepth).
+// The returned bitsPerSample is the output container size (8, 16, 24 or
+// 32), which determines the byte layout of DecodeSamples. The effective
+// depth is min(file native depth, maxOutputSampleBitDepth), rounded up to
+// whole bytes; use GetSampleBits for the number of significant bits.
 func (d *FlacDecoder) GetFormat() (int, int, int) {
 	return int(d.rate), d.channels, d.outputBytesPerSample * 8
 }
 
+// GetSampleBits returns the output container size and the number of
+// significant bits per sample. They differ for streams whose depth is not
+// a multiple of 8: a 20-bit file decodes to 24-bit containers with 20
+// significant bits. How the bits sit in the container is set by
+// SetSampleJustification.
+func (d *FlacDecoder) GetSampleBits() (containerBits, significantBits int) {
+	return d.outputBytesPerSample * 8, d.outputBits
+}
+
+// sampleShifts returns the right shift that reduces a decoded sample to
+// the output depth and the left shift that justifies it in its container.
+func (d *FlacDecoder) sampleShifts() (reduce, justify uint) {
+	if d.bitsPerSample > d.outputBits {
+		reduce = uint(d.bitsPerSample - d.outputBits)
+	}
+	if d.justification == LeftJustified {
+		justify = uint(d.outputBytesPerSample*8 - d.outputBits)
+	}
+	return reduce, justify
+}
+
 // DecodeSamples decodes the specified number of audio samples into the provided buffer.
 //
 // Parameters:
@@ -294,6 +635,10 @@ func (d *FlacDecoder) DecodeSamples(samples int, audio []byte) (int, error) {
 		return 0, fmt.Errorf("audio buffer too small: need %d bytes, got %d", bytesRequired, len(audio))
 	}
 
+	if d.readAhead != nil {
+		return d.drainSamples(samples, audio)
+	}
+
 	// Reset error state
 	d.lastError = nil
 
@@ -311,6 +656,12 @@ func (d *FlacDecoder) DecodeSamples(samples int, audio []byte) (int, error) {
 		// Check decoder state first
 		state := C.FLAC__stream_decoder_get_state(d.decoder)
 
+		if state == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+			if err := d.finishStream(); err != nil {
+				return samplesRead, err
+			}
+		}
+
 		// Check available data in buffer
 		available := d.ringBuffer.AvailableRead()
 		availableSamples := int(available) / (d.channels * d.outputBytesPerSample)
@@ -321,12 +672,13 @@ func (d *FlacDecoder) DecodeSamples(samples int, audio []byte) (int, error) {
 
 			// Determine how many bytes to read
 			bytesToRead := samplesToRead * d.channels * d.outputBytesPerSample
-			if state == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+			if state == C.FLAC__STREAM_DECODER_END_OF_STREAM && int(available) < bytesToRead {
 				// At EOF, read whatever is available
 				bytesToRead = int(available)
 			}
 
 			if bytesToRead > 0 {
+				d.seekFrame = nil
 				n, err := d.ringBuffer.Read(audio[offset : offset+bytesToRead])
 				if err != nil {
 					return samplesRead, fmt.Errorf("failed to read from buffer: %w", err)
@@ -342,7 +694,7 @@ func (d *FlacDecoder) DecodeSamples(samples int, audio []byte) (int, error) {
 				if samplesRead > 0 {
 					return samplesRead, nil
 				}
-				return 0, io.EOF
+				return 0, d.endOfStream()
 			}
 
 			// If we have enough data, we're done
@@ -372,10 +724,10 @@ func (d *FlacDecoder) DecodeSamples(samples int, audio []byte) (int, error) {
 				if samplesRead > 0 {
 					return samplesRead, nil
 				}
-				return 0, io.EOF
+				return 0, d.endOfStream()
 			}
 
-			return samplesRead, fmt.Errorf("decode samples error: %d", state)
+			return samplesRead, d.decoderError("decode samples")
 		}
 
 		// Check for callback errors
@@ -390,7 +742,12 @@ func (d *FlacDecoder) DecodeSamples(samples int, audio []byte) (int, error) {
 }
 
 // Seek seeks to the specified sample position.
+// Decoders opened with OpenReader are forward-only and cannot seek.
 func (d *FlacDecoder) Seek(offset int64, whence int) (int64, error) {
+	if d.reader != nil && d.seeker == nil {
+		return d.currentSample, errors.New("seek not supported: stream was opened with a forward-only reader")
+	}
+
 	seekSample := offset
 	if whence == io.SeekCurrent {
 		seekSample = d.currentSample + offset
@@ -406,13 +763,33 @@ func (d *FlacDecoder) Seek(offset int64, whence int) (int64, error) {
 		return d.currentSample, fmt.Errorf("cannot seek beyond end of stream (pos: %d, total: %d)", seekSample, d.totalSamples)
 	}
 
+	// Stop background decoding while the decoder is repositioned
+	if d.readAhead != nil {
+		d.readAhead.pause()
+		defer d.readAhead.resume()
+	}
+
 	// Reset ring buffer to discard stale data
 	d.ringBuffer.Reset()
+	d.requant.reset()
+	d.seekFrame = nil
+	d.invalidateMD5()
+	d.badFrameSample = -1
+
+	// When resampling, positions are in output samples. Start decoding
+	// early enough to fill the filter history for the target sample.
+	streamSample := seekSample
+	if d.resampler != nil {
+		streamSample = d.resampler.inputPosition(seekSample)
+		d.resampler.reset(streamSample, seekSample)
+	}
 
-	res := C.FLAC__stream_decoder_seek_absolute(d.decoder, C.FLAC__uint64(seekSample))
+	d.nextFrameSample = streamSample
+	d.seeking = true
+	res := C.FLAC__stream_decoder_seek_absolute(d.decoder, C.FLAC__uint64(streamSample))
+	d.seeking = false
 	if res == 0 {
-		state := C.FLAC__stream_decoder_get_state(d.decoder)
-		return d.currentSample, fmt.Errorf("seek failed, decoder state: %d", state)
+		return d.currentSample, d.decoderError("seek")
 	}
 
 	d.currentSample = seekSample
@@ -432,28 +809,7 @@ func decoderErrorCallback(d *C.FLAC__StreamDecoder, status C.FLAC__StreamDecoder
 	h := cgo.Handle(uintptr(data))
 	dec := h.Value().(*FlacDecoder)
 
-	var errMsg string
-	switch int(status) {
-	case 0: // FLAC__STREAM_DECODER_ERROR_STATUS_LOST_SYNC
-		errMsg = "lost sync"
-	case 1: // FLAC__STREAM_DECODER_ERROR_STATUS_BAD_HEADER
-		errMsg = "bad header"
-	case 2: // FLAC__STREAM_DECODER_ERROR_STATUS_FRAME_CRC_MISMATCH
-		errMsg = "frame CRC mismatch"
-	case 3: // FLAC__STREAM_DECODER_ERROR_STATUS_UNPARSEABLE_STREAM
-		errMsg = "unparseable stream"
-	case 4: // FLAC__STREAM_DECODER_ERROR_STATUS_BAD_METADATA
-		errMsg = "bad metadata"
-	case 5: // FLAC__STREAM_DECODER_ERROR_STATUS_OUT_OF_BOUNDS
-		errMsg = "out of bounds"
-	case 6: // FLAC__STREAM_DECODER_ERROR_STATUS_MISSING_FRAME
-		errMsg = "missing frame"
-	default:
-		errMsg = fmt.Sprintf("unknown error status: %d", status)
-	}
-
-	dec.setError(fmt.Errorf("FLAC decoder error: %s", errMsg))
-	slog.Error("FLAC decoder error callback", "error", errMsg, "status", int(status))
+	dec.reportStreamError(ErrorStatus(status))
 }
 
 //export decoderWriteCallback
@@ -475,75 +831,232 @@ func decoderWriteCallback(decoder *C.FLAC__StreamDecoder, frame *C.FLAC__Frame,
 		return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
 	}
 
-	// Use the actual number of channels from the decoder metadata
-	numChannels := dec.channels
+	// Apply the error policy (see SetErrorPolicy)
+	skip, err := dec.recoverFrame(frame)
+	if err != nil {
+		dec.setError(err)
+		return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
+	}
+	if skip {
+		return C.FLAC__STREAM_DECODER_WRITE_STATUS_CONTINUE
+	}
+
+	// View libFLAC's per-channel buffers as Go slices, reusing the slice
+	// headers between frames.
+	numChannels := dec.streamChannels
 	chSlice := unsafe.Slice(buffer, numChannels)
+	if cap(dec.chanBuf) < numChannels {
+		dec.chanBuf = make([][]int32, numChannels)
+	}
+	channels := dec.chanBuf[:numChannels]
+	for ch := range channels {
+		channels[ch] = unsafe.Slice((*int32)(unsafe.Pointer(chSlice[ch])), sampleCount)
+	}
+	dec.hashFrame(channels, int(sampleCount))
 
-	// Create slices for each channel
-	channels := make([][]C.FLAC__int32, numChannels)
-	for ch := 0; ch < numChannels; ch++ {
-		channels[ch] = unsafe.Slice(chSlice[ch], sampleCount)
-	}
-
-	// Interleave samples from all channels
-	for i := int64(0); i < sampleCount; i++ {
-		for ch := 0; ch < dec.channels; ch++ {
-			sample := int32(channels[ch][i])
-
-			switch dec.streamBytesPerSample {
-			case 3:
-				int32toInt24LEBytes(sample, &dec.b24)
-				if dec.outputBytesPerSample >= 3 {
-					if _, err := dec.ringBuffer.Write(dec.b24[:3]); err != nil {
-						slog.Error("Failed to write 24-bit sample", "error", err)
-						dec.setError(err)
-						return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
-					}
-				} else {
-					if _, err := dec.ringBuffer.Write(dec.b24[1:3]); err != nil {
-						slog.Error("Failed to write 16-bit sample from 24-bit", "error", err)
-						dec.setError(err)
-						return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
-					}
-				}
-			case 2:
-				dec.b16[0] = byte(sample)
-				dec.b16[1] = byte(sample >> 8)
-				if _, err := dec.ringBuffer.Write(dec.b16[:2]); err != nil {
-					slog.Error("Failed to write 16-bit sample", "error", err)
-					dec.setError(err)
-					return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
-				}
-			case 1:
-				// 8-bit samples
-				if _, err := dec.ringBuffer.Write([]byte{byte(sample)}); err != nil {
-					slog.Error("Failed to write 8-bit sample", "error", err)
-					dec.setError(err)
-					return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
-				}
-			case 4:
-				// 32-bit samples (little-endian)
-				if _, err := dec.ringBuffer.Write([]byte{
-					byte(sample),
-					byte(sample >> 8),
-					byte(sample >> 16),
-					byte(sample >> 24),
-				}); err != nil {
-					slog.Error("Failed to write 32-bit sample", "error", err)
-					dec.setError(err)
-					return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
-				}
-			default:
-				// Should never happen if validation is correct
-				slog.Error("unsupported stream bytes per sample", "bytes", dec.streamBytesPerSample)
-				err := fmt.Errorf("unsupported stream bytes per sample: %d", dec.streamBytesPerSample)
-				dec.setError(err)
-				return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
+	if dec.frameCapture != nil {
+		clear(channels)
+		*dec.frameCapture = newFrame(frame, buffer, dec.analysis)
+		return C.FLAC__STREAM_DECODER_WRITE_STATUS_CONTINUE
+	}
+	if dec.seeking {
+		// Keep the frame libFLAC delivers at the seek target so that
+		// DecodeFrame can return it; it also goes to the ring buffer.
+		dec.seekFrame = newFrame(frame, buffer, dec.analysis)
+	}
+
+	// Interleave samples from all output channels, applying any channel
+	// map or mix matrix (see SetChannelMap, SetChannelMatrix).
+	mixed := dec.interleave(channels, int(sampleCount))
+	clear(channels) // don't keep pointers into libFLAC's buffers
+
+	if dec.resampler != nil {
+		mixed = dec.resampler.process(mixed)
+	}
+
+	if err := dec.writeSamples(mixed); err != nil {
+		dec.setError(err)
+		return C.FLAC__STREAM_DECODER_WRITE_STATUS_ABORT
+	}
+
+	return C.FLAC__STREAM_DECODER_WRITE_STATUS_CONTINUE
+}
+
+// interleave combines a frame's per-channel samples into one interleaved
+// slice of output channels. The result is valid until the next call.
+func (d *FlacDecoder) interleave(in [][]int32, n int) []int32 {
+	need := n * d.channels
+	if cap(d.mixBuf) < need {
+		d.mixBuf = make([]int32, need)
+	}
+	out := d.mixBuf[:need]
+
+	if d.mixMatrix != nil {
+		for i := 0; i < n; i++ {
+			for ch := 0; ch < d.channels; ch++ {
+				out[i*d.channels+ch] = d.outputSample(in, ch, i)
 			}
 		}
+		return out
 	}
 
-	return C.FLAC__STREAM_DECODER_WRITE_STATUS_CONTINUE
+	for ch := 0; ch < d.channels; ch++ {
+		src := in[ch]
+		if d.channelMap != nil {
+			src = in[d.channelMap[ch]]
+		}
+		src = src[:n]
+		for i, v := range src {
+			out[i*d.channels+ch] = v
+		}
+	}
+	return out
+}
+
+// writeSamples converts interleaved samples at the stream's bit depth to
+// the output format. Samples go to directOut while it has room and are
+// otherwise appended to the ring buffer in a single write.
+func (d *FlacDecoder) writeSamples(samples []int32) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	// Reduce to the output depth (see SetRequantizeMode) in place.
+	reduce, justify := d.sampleShifts()
+	if reduce > 0 {
+		for i, sample := range samples {
+			samples[i] = d.requant.quantize(sample, i%d.channels)
+		}
+	}
+
+	if d.directOut != nil {
+		n := copy(d.directOut, samples)
+		justifySamples(d.directOut[:n], d.outputBytesPerSample, justify)
+		d.directOut = d.directOut[n:]
+		samples = samples[n:]
+	}
+
+	size := len(samples) * d.outputBytesPerSample
+	if size == 0 {
+		return nil
+	}
+
+	// Grow the ring buffer for frames larger than STREAMINFO announced or
+	// than an explicit capacity allows. The read-ahead buffer is sized for
+	// the largest frame up front and cannot be replaced while it runs.
+	if d.readAhead == nil {
+		d.reserveRing(size)
+	}
+
+	if cap(d.packBuf) < size {
+		d.packBuf = make([]byte, size)
+	}
+	out := d.packBuf[:size]
+	if err := packSamples(out, samples, d.outputBytesPerSample, justify); err != nil {
+		slog.Error("unsupported output bytes per sample", "bytes", d.outputBytesPerSample)
+		return err
+	}
+
+	if _, err := d.ringBuffer.Write(out); err != nil {
+		slog.Error("Failed to write samples", "bytes", size, "error", err)
+		return err
+	}
+	return nil
+}
+
+// finishStream outputs what is held back once libFLAC reaches the end of
+// the stream: silence for missing frames at the end when zero-filling, and
+// the resampler's last output samples. Calling it again does nothing.
+func (d *FlacDecoder) finishStream() error {
+	// fillGap counts in stream samples, before any resampling.
+	if d.errorPolicy == ErrorPolicyZeroFill && d.nextFrameSample < d.streamTotalSamples {
+		if err := d.fillGap(d.streamTotalSamples); err != nil {
+			return err
+		}
+	}
+	if d.resampler != nil && !d.resampler.flushed {
+		return d.writeSamples(d.resampler.flush())
+	}
+	return nil
+}
+
+// packSamples writes samples as little-endian integers of bytesPerSample
+// bytes each, shifted left by justify bits to place the significant bits
+// in the container.
+func packSamples(out []byte, samples []int32, bytesPerSample int, justify uint) error {
+	switch bytesPerSample {
+	case 1:
+		for i, v := range samples {
+			out[i] = byte(v << justify)
+		}
+	case 2:
+		out = out[:len(samples)*2]
+		for i, v := range samples {
+			v <<= justify
+			out[2*i] = byte(v)
+			out[2*i+1] = byte(v >> 8)
+		}
+	case 3:
+		out = out[:len(samples)*3]
+		for i, v := range samples {
+			v <<= justify
+			out[3*i] = byte(v)
+			out[3*i+1] = byte(v >> 8)
+			out[3*i+2] = byte(v >> 16)
+		}
+	case 4:
+		out = out[:len(samples)*4]
+		for i, v := range samples {
+			v <<= justify
+			out[4*i] = byte(v)
+			out[4*i+1] = byte(v >> 8)
+			out[4*i+2] = byte(v >> 16)
+			out[4*i+3] = byte(v >> 24)
+		}
+	default:
+		// Should never happen if validation is correct
+		return fmt.Errorf("unsupported output bytes per sample: %d", bytesPerSample)
+	}
+	return nil
+}
+
+// justifySamples shifts samples left by justify bits and sign-extends
+// them from the container, giving the values packSamples would store.
+func justifySamples(samples []int32, bytesPerSample int, justify uint) {
+	ext := uint(32 - 8*bytesPerSample)
+	if justify == 0 && ext == 0 {
+		return
+	}
+	for i, v := range samples {
+		samples[i] = v << justify << ext >> ext
+	}
+}
+
+// maxFrameSamples returns the largest number of output samples (per
+// channel) a single frame can produce.
+func (d *FlacDecoder) maxFrameSamples() int {
+	blocksize := d.maxBlocksize
+	if blocksize == 0 {
+		blocksize = maxFrameBlocksize
+	}
+	if d.resampler != nil {
+		return int(d.resampler.outputLength(int64(blocksize))) + 1
+	}
+	return blocksize
+}
+
+// reserveRing makes room for n more bytes in the ring buffer, replacing
+// it with a larger one if needed. Buffered data is kept. It must not be
+// called while the read-ahead goroutine is running.
+func (d *FlacDecoder) reserveRing(n int) {
+	if uint64(n) <= d.ringBuffer.AvailableWrite() {
+		return
+	}
+
+	buffered := make([]byte, d.ringBuffer.AvailableRead())
+	d.ringBuffer.Read(buffered)
+	d.ringBuffer = ringbuffer.New(uint64(len(buffered) + n))
+	d.ringBuffer.Write(buffered)
 }
 
 //export decoderMetadataCallback
@@ -551,41 +1064,37 @@ func decoderMetadataCallback(d *C.FLAC__StreamDecoder, metadata *C.FLAC__StreamM
 	h := cgo.Handle(uintptr(client_data))
 	dec := h.Value().(*FlacDecoder)
 
-	if metadata._type == C.FLAC__METADATA_TYPE_STREAMINFO {
-		dec.channels = int(C.get_decoder_channels(metadata))
+	switch metadata._type {
+	case C.FLAC__METADATA_TYPE_STREAMINFO:
+		dec.streamChannels = int(C.get_decoder_channels(metadata))
 		dec.bitsPerSample = int(C.get_decoder_depth(metadata))
 		dec.rate = int64(C.get_decoder_rate(metadata))
 		dec.streamBytesPerSample = (dec.bitsPerSample + 7) / 8
-		dec.totalSamples = int64(C.get_total_samples(metadata))
+		dec.streamTotalSamples = int64(C.get_total_samples(metadata))
+		dec.totalSamples = dec.streamTotalSamples
+		dec.maxBlocksize = int(C.get_max_blocksize(metadata))
 
 		// Recalculate effective output bytes per sample now that we know
 		// the file's native bit depth. Output at native depth unless
-		// maxOutputSampleBitDepth is explicitly lower.
+		// maxOutputSampleBitDepth is explicitly lower. Depths that are not
+		// a multiple of 8 are rounded up to the next whole byte.
 		effectiveBits := dec.bitsPerSample
 		if dec.maxOutputSampleBitDepth > 0 && dec.maxOutputSampleBitDepth < dec.bitsPerSample {
 			effectiveBits = dec.maxOutputSampleBitDepth
 		}
-		dec.outputBytesPerSample = effectiveBits / 8
-	}
-}
-
-func getStreamDecoderInitStatusString(status C.FLAC__StreamDecoderInitStatus) string {
-	var theCArray **C.char = (**C.char)(unsafe.Pointer(&C.FLAC__StreamDecoderInitStatusString))
-	length := 5
-	slice := unsafe.Slice(theCArray, length)
-
-	idx := int(status)
-	if idx < 0 || idx >= length {
-		return fmt.Sprintf("unknown status %d", idx)
-	}
-	return C.GoString(slice[idx])
-}
-
-func int32toInt24LEBytes(n int32, out *[3]byte) {
-	if (n & 0x800000) > 0 {
-		n |= ^0xffffff
+		dec.outputBits = effectiveBits
+		dec.outputBytesPerSample = (effectiveBits + 7) / 8
+		if err := dec.configureChannels(); err != nil {
+			dec.setError(err)
+		}
+		dec.requant.configure(dec.channels, dec.bitsPerSample, dec.outputBits)
+		dec.configureResampler()
+		dec.configureMD5(metadata)
+	case C.FLAC__METADATA_TYPE_VORBIS_COMMENT:
+		dec.tags = parseVorbisComment(metadata)
+	case C.FLAC__METADATA_TYPE_PICTURE:
+		dec.pictures = append(dec.pictures, parsePicture(metadata))
+	case C.FLAC__METADATA_TYPE_SEEKTABLE:
+		dec.seekTable = parseSeekTable(metadata)
 	}
-	out[2] = byte(n >> 16)
-	out[1] = byte(n >> 8)
-	out[0] = byte(n)
 }
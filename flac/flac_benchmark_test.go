@@ -1,6 +1,8 @@
 package flac
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -200,3 +202,65 @@ func init() {
 		os.Setenv("FLAC_BENCHMARK_FILE", absPath)
 	}
 }
+
+// BenchmarkFlacDecoder_BitDepths decodes a synthetic multichannel file at
+// each bit depth, covering the frame write path for every output size.
+func BenchmarkFlacDecoder_BitDepths(b *testing.B) {
+	const (
+		numSamples = 48000
+		channels   = 6
+	)
+	for _, bps := range []int{8, 16, 24, 32} {
+		b.Run(formatTestName(48000, channels, bps), func(b *testing.B) {
+			path, _ := encodeTestFile(b, 48000, channels, bps, numSamples)
+
+			dec, err := NewFlacFrameDecoder(32)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer dec.Delete()
+
+			audioSamples := 4096
+			audio := make([]byte, audioSamples*channels*4)
+
+			b.SetBytes(int64(numSamples * channels * ((bps + 7) / 8)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := dec.Open(path); err != nil {
+					b.Fatal(err)
+				}
+				for {
+					n, err := dec.DecodeSamples(audioSamples, audio)
+					if err == io.EOF || n == 0 {
+						break
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				dec.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkPackSamples measures packing one 4096-sample stereo frame into
+// output bytes.
+func BenchmarkPackSamples(b *testing.B) {
+	samples := generateTestSignal(4096, 2, 16)
+	out := make([]byte, len(samples)*4)
+
+	for _, bytesPerSample := range []int{1, 2, 3, 4} {
+		b.Run(fmt.Sprintf("%dbit", bytesPerSample*8), func(b *testing.B) {
+			b.SetBytes(int64(len(samples) * bytesPerSample))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := packSamples(out, samples, bytesPerSample, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package flac
 
 import (
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+
+	"github.com/drgolem/ringbuffer"
 )
 
 func TestNewFlacFrameDecoder_ValidBitDepths(t *testing.T) {
@@ -39,6 +45,79 @@ func TestNewFlacFrameDecoder_InvalidBitDepths(t *testing.T) {
 	}
 }
 
+func TestNewFlacFrameDecoderWithCapacity_Validation(t *testing.T) {
+	for _, capacity := range []int{-1, maxRingBufferCapacity + 1} {
+		if dec, err := NewFlacFrameDecoderWithCapacity(16, capacity); err == nil {
+			t.Errorf("NewFlacFrameDecoderWithCapacity(16, %d) should have failed", capacity)
+			dec.Delete()
+		}
+	}
+
+	dec, err := NewFlacFrameDecoderWithCapacity(16, 3000)
+	if err != nil {
+		t.Fatalf("NewFlacFrameDecoderWithCapacity failed: %v", err)
+	}
+	defer dec.Delete()
+	if size := dec.ringBuffer.Size(); size != 4096 {
+		t.Errorf("ring buffer size = %d, want 4096", size)
+	}
+}
+
+func TestReserveRing_Unit(t *testing.T) {
+	d := &FlacDecoder{ringBuffer: ringbuffer.New(16)}
+	d.ringBuffer.Write([]byte("0123456789"))
+
+	d.reserveRing(6)
+	if size := d.ringBuffer.Size(); size != 16 {
+		t.Errorf("reserveRing grew the buffer to %d with enough room", size)
+	}
+
+	d.reserveRing(100)
+	if free := d.ringBuffer.AvailableWrite(); free < 100 {
+		t.Errorf("AvailableWrite = %d after reserveRing(100)", free)
+	}
+	buf := make([]byte, 20)
+	n, _ := d.ringBuffer.Read(buf)
+	if got := string(buf[:n]); got != "0123456789" {
+		t.Errorf("buffered data = %q after growing, want %q", got, "0123456789")
+	}
+}
+
+func TestFlacDecoder_LargeFrames(t *testing.T) {
+	// 8 channels of 32-bit samples in 4096-sample blocks need 128 KiB per
+	// frame, more than the default ring buffer holds.
+	const numSamples = 20000
+	path, orig := encodeTestFile(t, 48000, 8, 32, numSamples)
+
+	for _, capacity := range []int{0, 1024} {
+		dec, err := NewFlacFrameDecoderWithCapacity(32, capacity)
+		if err != nil {
+			t.Fatalf("Failed to create decoder: %v", err)
+		}
+		if err := dec.Open(path); err != nil {
+			dec.Delete()
+			t.Fatalf("Open failed: %v", err)
+		}
+
+		out := make([]int32, numSamples*8)
+		n, err := dec.DecodeInt32(numSamples, out)
+		if err != nil && err != io.EOF {
+			t.Fatalf("capacity %d: DecodeInt32 failed: %v", capacity, err)
+		}
+		if n != numSamples {
+			t.Fatalf("capacity %d: decoded %d samples, want %d", capacity, n, numSamples)
+		}
+		for i := range orig {
+			if out[i] != orig[i] {
+				t.Fatalf("capacity %d: sample %d = %d, want %d", capacity, i, out[i], orig[i])
+			}
+		}
+
+		dec.Close()
+		dec.Delete()
+	}
+}
+
 func TestDecodeSamples_Validation(t *testing.T) {
 	dec, err := NewFlacFrameDecoder(16)
 	if err != nil {
@@ -136,7 +215,7 @@ func TestSeek_Validation(t *testing.T) {
 	// It might fail because decoder isn't actually open, but it shouldn't panic
 }
 
-func TestInt32toInt24LEBytes(t *testing.T) {
+func TestPackSamplesInt24(t *testing.T) {
 	tests := []struct {
 		input    int32
 		expected [3]byte
@@ -151,11 +230,65 @@ func TestInt32toInt24LEBytes(t *testing.T) {
 
 	for _, tt := range tests {
 		var result [3]byte
-		int32toInt24LEBytes(tt.input, &result)
+		if err := packSamples(result[:], []int32{tt.input}, 3, 0); err != nil {
+			t.Fatalf("packSamples(%d) failed: %v", tt.input, err)
+		}
 		if result != tt.expected {
-			t.Errorf("int32toInt24LEBytes(%d) = %v, want %v", tt.input, result, tt.expected)
+			t.Errorf("packSamples(%d) = %v, want %v", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestPackSamples_Unit(t *testing.T) {
+	for _, bps := range []int{8, 16, 24, 32} {
+		samples := generateTestSignal(1000, 2, bps)
+		out := make([]byte, len(samples)*bps/8)
+		if err := packSamples(out, samples, bps/8, 0); err != nil {
+			t.Fatalf("packSamples(%d bytes) failed: %v", bps/8, err)
+		}
+
+		got := make([]int32, len(samples))
+		if n := PCMToInt32(out, bps, got); n != len(samples) {
+			t.Fatalf("%d-bit: PCMToInt32 returned %d samples, want %d", bps, n, len(samples))
+		}
+		for i := range samples {
+			if got[i] != samples[i] {
+				t.Fatalf("%d-bit: sample %d = %d, want %d", bps, i, got[i], samples[i])
+			}
 		}
 	}
+
+	// A 12-bit sample left-justified in a 16-bit container
+	out := make([]byte, 2)
+	if err := packSamples(out, []int32{-2048}, 2, 4); err != nil {
+		t.Fatalf("packSamples failed: %v", err)
+	}
+	if out[0] != 0x00 || out[1] != 0x80 {
+		t.Errorf("justified sample = % x, want 00 80", out)
+	}
+
+	if err := packSamples(out, []int32{0}, 5, 0); err == nil {
+		t.Error("Expected error for 5 bytes per sample")
+	}
+}
+
+func TestInterleave_Unit(t *testing.T) {
+	in := [][]int32{{1, 2, 3}, {10, 20, 30}, {100, 200, 300}}
+
+	d := &FlacDecoder{channels: 3}
+	if got, want := d.interleave(in, 3), []int32{1, 10, 100, 2, 20, 200, 3, 30, 300}; !slices.Equal(got, want) {
+		t.Errorf("interleave = %v, want %v", got, want)
+	}
+
+	d = &FlacDecoder{channels: 2, channelMap: []int{2, 0}}
+	if got, want := d.interleave(in, 3), []int32{100, 1, 200, 2, 300, 3}; !slices.Equal(got, want) {
+		t.Errorf("interleave with channel map = %v, want %v", got, want)
+	}
+
+	// The scratch buffer is reused once it has grown.
+	if allocs := testing.AllocsPerRun(10, func() { d.interleave(in, 3) }); allocs != 0 {
+		t.Errorf("interleave allocated %.0f times per frame", allocs)
+	}
 }
 
 func TestGetVersion(t *testing.T) {
@@ -512,3 +645,44 @@ func TestFlacDecoder_DoubleClose(t *testing.T) {
 		t.Errorf("Second close failed: %v", err)
 	}
 }
+
+func TestIsOggHeader(t *testing.T) {
+	tests := []struct {
+		header []byte
+		want   bool
+	}{
+		{[]byte("OggS\x00\x02"), true},
+		{[]byte("fLaC"), false},
+		{[]byte("ID3\x04"), false},
+		{[]byte("Og"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := isOggHeader(tt.header); got != tt.want {
+			t.Errorf("isOggHeader(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFlacDecoder_OpenOggInvalid(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "broken.oga")
+	if err := os.WriteFile(tmpFile, []byte("OggS garbage that is not a valid page"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	err = dec.Open(tmpFile)
+	if err == nil {
+		t.Error("Opening a broken Ogg file should fail")
+		dec.Close()
+	}
+	if !OggSupported() && !errors.Is(err, ErrOggNotSupported) {
+		t.Errorf("Expected ErrOggNotSupported without Ogg support, got %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/format.h>
+#include <FLAC/stream_decoder.h>
+
+extern int
+get_frame_number_type(const FLAC__Frame *frame);
+
+extern FLAC__uint64
+get_frame_number(const FLAC__Frame *frame);
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// ChannelAssignment describes how the channels of a FLAC frame are coded.
+// Stereo frames may store a side channel (the difference of left and
+// right) in place of one of the two; samples are always reconstructed to
+// left/right before they are returned.
+type ChannelAssignment int
+
+const (
+	ChannelIndependent ChannelAssignment = iota // channels coded independently
+	ChannelLeftSide                             // left, side
+	ChannelRightSide                            // side, right
+	ChannelMidSide                              // mid, side
+)
+
+func (a ChannelAssignment) String() string {
+	switch a {
+	case ChannelIndependent:
+		return "INDEPENDENT"
+	case ChannelLeftSide:
+		return "LEFT_SIDE"
+	case ChannelRightSide:
+		return "RIGHT_SIDE"
+	case ChannelMidSide:
+		return "MID_SIDE"
+	default:
+		return fmt.Sprintf("ChannelAssignment(%d)", int(a))
+	}
+}
+
+// FrameNumberType tells whether a frame header carried a frame number
+// (fixed-blocksize streams) or a sample number (variable-blocksize streams).
+type FrameNumberType int
+
+const (
+	FrameNumberTypeFrame FrameNumberType = iota
+	FrameNumberTypeSample
+)
+
+func (t FrameNumberType) String() string {
+	switch t {
+	case FrameNumberTypeFrame:
+		return "FRAME_NUMBER"
+	case FrameNumberTypeSample:
+		return "SAMPLE_NUMBER"
+	default:
+		return fmt.Sprintf("FrameNumberType(%d)", int(t))
+	}
+}
+
+// FrameInfo holds the header and footer fields of a decoded FLAC frame.
+type FrameInfo struct {
+	Blocksize         int // samples per channel in this frame
+	SampleRate        int
+	Channels          int
+	ChannelAssignment ChannelAssignment
+	BitsPerSample     int
+
+	// NumberType and FrameNumber are as delivered by libFLAC. FrameNumber
+	// is only meaningful when NumberType is FrameNumberTypeFrame; libFLAC
+	// normally converts frame numbers of fixed-blocksize streams to sample
+	// numbers before the frame reaches the decoder.
+	NumberType  FrameNumberType
+	FrameNumber int64
+
+	// SampleNumber is the stream position of the first sample in the
+	// frame, per channel.
+	SampleNumber int64
+
+	HeaderCRC uint8  // CRC-8 of the frame header
+	FooterCRC uint16 // CRC-16 of the whole frame
+}
+
+// Frame is a single decoded FLAC frame.
+type Frame struct {
+	FrameInfo
+
+	// Samples holds one slice per channel, each Blocksize values long, at
+	// the stream's native bit depth (right-justified, sign-extended).
+	// Output options such as maxOutputSampleBitDepth, justification,
+	// requantization and channel mapping do not apply.
+	Samples [][]int32
+
+	// Subframes describes the encoding of each channel. Set only in
+	// analysis mode (see SetAnalysisMode).
+	Subframes []Subframe
+
+	// StreamOffset is the byte offset of the frame in the stream and
+	// EncodedSize its size in bytes. Set only in analysis mode, and only
+	// for frames returned by DecodeFrame from a seekable native FLAC
+	// source; otherwise StreamOffset is -1 and EncodedSize 0.
+	StreamOffset int64
+	EncodedSize  int
+}
+
+// DecodeFrame decodes the next FLAC frame and returns its header fields
+// and per-channel samples. Returns io.EOF once the stream is exhausted.
+//
+// DecodeFrame and DecodeSamples share the stream position, but a frame
+// that DecodeSamples has started reading cannot be returned as a Frame:
+// DecodeFrame fails while decoded samples are still buffered. Immediately
+// after Seek, DecodeFrame returns the remainder of the frame containing
+// the target sample.
+func (d *FlacDecoder) DecodeFrame() (*Frame, error) {
+	if d.decoder == nil {
+		return nil, errors.New("decoder not initialized")
+	}
+	if d.channels <= 0 {
+		return nil, errors.New("decoder not initialized: no stream open")
+	}
+
+	if d.resampler != nil {
+		return nil, errors.New("DecodeFrame is not available while resampling")
+	}
+	if d.readAhead != nil {
+		return nil, errors.New("DecodeFrame is not available with read-ahead")
+	}
+
+	if d.seekFrame != nil {
+		f := d.seekFrame
+		d.seekFrame = nil
+		d.ringBuffer.Reset()
+		d.currentSample = f.SampleNumber + int64(f.Blocksize)
+		return f, nil
+	}
+	if d.ringBuffer.AvailableRead() > 0 {
+		return nil, errors.New("decoded samples are buffered; drain them with DecodeSamples before calling DecodeFrame")
+	}
+
+	d.lastError = nil
+	var captured *Frame
+	d.frameCapture = &captured
+	defer func() { d.frameCapture = nil }()
+
+	start := int64(-1)
+	for captured == nil {
+		if C.FLAC__stream_decoder_get_state(d.decoder) == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+			return nil, d.endOfStream()
+		}
+
+		if d.analysis {
+			start = d.decodePosition()
+		}
+		res := C.FLAC__stream_decoder_process_single(d.decoder)
+		if d.lastError != nil {
+			err := d.lastError
+			d.lastError = nil
+			return nil, err
+		}
+		if res == 0 {
+			state := C.FLAC__stream_decoder_get_state(d.decoder)
+			if state == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+				return nil, d.endOfStream()
+			}
+			return nil, d.decoderError("decode frame")
+		}
+	}
+
+	if d.analysis && start >= 0 {
+		if end := d.decodePosition(); end > start {
+			captured.StreamOffset = start
+			captured.EncodedSize = int(end - start)
+		}
+	}
+
+	d.currentSample = captured.SampleNumber + int64(captured.Blocksize)
+	return captured, nil
+}
+
+// FrameHandler receives each decoded frame from Run. samples holds one
+// slice per channel, as in Frame.Samples; the handler may keep it.
+// Returning an error stops decoding.
+type FrameHandler func(frame FrameInfo, samples [][]int32) error
+
+// Run decodes the rest of the stream, calling handler for each frame in
+// order. It returns nil at the end of the stream, or the first error from
+// decoding or from handler.
+//
+// Run is built on DecodeFrame and shares its restrictions. When handler
+// returns an error, decoding stops after that frame and the decoder stays
+// usable: a later Run, DecodeFrame or DecodeSamples continues with the
+// next frame, and Seek works as usual.
+func (d *FlacDecoder) Run(handler FrameHandler) error {
+	return d.RunContext(context.Background(), handler)
+}
+
+// RunContext is like Run but also stops, returning ctx.Err(), once ctx is
+// done. The context is checked between frames.
+func (d *FlacDecoder) RunContext(ctx context.Context, handler FrameHandler) error {
+	if handler == nil {
+		return errors.New("frame handler is nil")
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		f, err := d.DecodeFrame()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := handler(f.FrameInfo, f.Samples); err != nil {
+			return err
+		}
+	}
+}
+
+// newFrame copies a frame delivered to the write callback, including
+// its subframe details if analyze is set.
+func newFrame(frame *C.FLAC__Frame, buffer **C.FLAC__int32, analyze bool) *Frame {
+	f := &Frame{FrameInfo: newFrameInfo(frame), StreamOffset: -1}
+	if analyze {
+		f.Subframes = readSubframes(frame)
+	}
+
+	chSlice := unsafe.Slice(buffer, f.Channels)
+	samples := make([]int32, f.Channels*f.Blocksize)
+	f.Samples = make([][]int32, f.Channels)
+	for ch := range f.Samples {
+		src := unsafe.Slice((*int32)(unsafe.Pointer(chSlice[ch])), f.Blocksize)
+		f.Samples[ch] = samples[ch*f.Blocksize : (ch+1)*f.Blocksize : (ch+1)*f.Blocksize]
+		copy(f.Samples[ch], src)
+	}
+	return f
+}
+
+// newFrameInfo extracts the header and footer fields of a frame.
+func newFrameInfo(frame *C.FLAC__Frame) FrameInfo {
+	info := FrameInfo{
+		Blocksize:         int(frame.header.blocksize),
+		SampleRate:        int(frame.header.sample_rate),
+		Channels:          int(frame.header.channels),
+		ChannelAssignment: ChannelAssignment(frame.header.channel_assignment),
+		BitsPerSample:     int(frame.header.bits_per_sample),
+		NumberType:        FrameNumberType(C.get_frame_number_type(frame)),
+		HeaderCRC:         uint8(frame.header.crc),
+		FooterCRC:         uint16(frame.footer.crc),
+	}
+
+	number := int64(C.get_frame_number(frame))
+	if info.NumberType == FrameNumberTypeFrame {
+		// Fallback only: libFLAC converts frame numbers to sample numbers
+		// before the write callback. Assumes a fixed blocksize.
+		info.FrameNumber = number
+		info.SampleNumber = number * int64(info.Blocksize)
+	} else {
+		info.SampleNumber = number
+	}
+	return info
+}
@@ -0,0 +1,287 @@
+package flac
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFlacDecoder_DecodeFrame(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		t.Run(formatTestName(44100, channels, 16), func(t *testing.T) {
+			numSamples := 20000
+			path, orig := encodeTestFile(t, 44100, channels, 16, numSamples)
+
+			dec, err := NewFlacFrameDecoder(16)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.Open(path); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer dec.Close()
+
+			var next int64
+			for {
+				f, err := dec.DecodeFrame()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("DecodeFrame failed: %v", err)
+				}
+
+				if f.SampleNumber != next {
+					t.Fatalf("frame SampleNumber = %d, want %d", f.SampleNumber, next)
+				}
+				if f.SampleRate != 44100 || f.Channels != channels || f.BitsPerSample != 16 {
+					t.Fatalf("frame format = %d/%d/%d, want 44100/%d/16",
+						f.SampleRate, f.Channels, f.BitsPerSample, channels)
+				}
+				if channels == 1 && f.ChannelAssignment != ChannelIndependent {
+					t.Errorf("mono frame has channel assignment %s", f.ChannelAssignment)
+				}
+				if len(f.Samples) != channels {
+					t.Fatalf("frame has %d channel slices, want %d", len(f.Samples), channels)
+				}
+
+				for ch := range f.Samples {
+					if len(f.Samples[ch]) != f.Blocksize {
+						t.Fatalf("channel %d has %d samples, want %d", ch, len(f.Samples[ch]), f.Blocksize)
+					}
+					for i, v := range f.Samples[ch] {
+						if want := orig[(int(f.SampleNumber)+i)*channels+ch]; v != want {
+							t.Fatalf("frame at %d, channel %d, sample %d = %d, want %d",
+								f.SampleNumber, ch, i, v, want)
+						}
+					}
+				}
+
+				next += int64(f.Blocksize)
+				if dec.TellCurrentSample() != next {
+					t.Errorf("TellCurrentSample = %d, want %d", dec.TellCurrentSample(), next)
+				}
+			}
+
+			if next != int64(numSamples) {
+				t.Errorf("frames covered %d samples, want %d", next, numSamples)
+			}
+		})
+	}
+}
+
+func TestFlacDecoder_DecodeFrameAfterSeek(t *testing.T) {
+	path, orig := encodeTestFile(t, 48000, 2, 16, 30000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	const target = 12345
+	if _, err := dec.Seek(target, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+
+	f, err := dec.DecodeFrame()
+	if err != nil {
+		t.Fatalf("DecodeFrame after seek failed: %v", err)
+	}
+	if f.SampleNumber != target {
+		t.Fatalf("frame SampleNumber = %d, want %d", f.SampleNumber, target)
+	}
+	for i, v := range f.Samples[0] {
+		if want := orig[(target+i)*2]; v != want {
+			t.Fatalf("sample %d = %d, want %d", i, v, want)
+		}
+	}
+
+	// The following frame continues where the partial one ended.
+	g, err := dec.DecodeFrame()
+	if err != nil {
+		t.Fatalf("DecodeFrame failed: %v", err)
+	}
+	if g.SampleNumber != f.SampleNumber+int64(f.Blocksize) {
+		t.Errorf("next frame SampleNumber = %d, want %d", g.SampleNumber, f.SampleNumber+int64(f.Blocksize))
+	}
+}
+
+func TestFlacDecoder_DecodeFrameWithBufferedSamples(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 10000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	buf := make([]byte, 100*2*2)
+	if _, err := dec.DecodeSamples(100, buf); err != nil {
+		t.Fatalf("DecodeSamples failed: %v", err)
+	}
+	if _, err := dec.DecodeFrame(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("DecodeFrame with buffered samples: err = %v, want an error", err)
+	}
+}
+
+func TestFlacDecoder_DecodeFrameValidation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if _, err := dec.DecodeFrame(); err == nil {
+		t.Error("DecodeFrame without Open should fail")
+	}
+}
+
+func TestFlacDecoder_Run(t *testing.T) {
+	const numSamples = 20000
+	path, orig := encodeTestFile(t, 44100, 2, 16, numSamples)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	var next int64
+	err = dec.Run(func(frame FrameInfo, samples [][]int32) error {
+		if frame.SampleNumber != next {
+			t.Fatalf("frame SampleNumber = %d, want %d", frame.SampleNumber, next)
+		}
+		for ch := range samples {
+			for i, v := range samples[ch] {
+				if want := orig[(int(frame.SampleNumber)+i)*2+ch]; v != want {
+					t.Fatalf("frame at %d, channel %d, sample %d = %d, want %d",
+						frame.SampleNumber, ch, i, v, want)
+				}
+			}
+		}
+		next += int64(frame.Blocksize)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if next != numSamples {
+		t.Errorf("frames covered %d samples, want %d", next, numSamples)
+	}
+}
+
+func TestFlacDecoder_RunHandlerError(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 20000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	errStop := errors.New("stop")
+	var frames int
+	var stopAt int64
+	err = dec.Run(func(frame FrameInfo, samples [][]int32) error {
+		frames++
+		if frames == 2 {
+			stopAt = frame.SampleNumber + int64(frame.Blocksize)
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Run returned %v, want %v", err, errStop)
+	}
+	if frames != 2 {
+		t.Errorf("handler called %d times, want 2", frames)
+	}
+
+	// Decoding continues with the frame after the one that stopped Run.
+	f, err := dec.DecodeFrame()
+	if err != nil {
+		t.Fatalf("DecodeFrame after Run failed: %v", err)
+	}
+	if f.SampleNumber != stopAt {
+		t.Errorf("next frame SampleNumber = %d, want %d", f.SampleNumber, stopAt)
+	}
+}
+
+func TestFlacDecoder_RunContextCanceled(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 1, 16, 20000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var frames int
+	err = dec.RunContext(ctx, func(frame FrameInfo, samples [][]int32) error {
+		frames++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunContext returned %v, want %v", err, context.Canceled)
+	}
+	if frames != 1 {
+		t.Errorf("handler called %d times, want 1", frames)
+	}
+}
+
+func TestFlacDecoder_RunValidation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.Run(nil); err == nil {
+		t.Error("Run with nil handler should fail")
+	}
+	if err := dec.Run(func(FrameInfo, [][]int32) error { return nil }); err == nil {
+		t.Error("Run without Open should fail")
+	}
+}
+
+func TestChannelAssignment_StringUnit(t *testing.T) {
+	tests := map[ChannelAssignment]string{
+		ChannelIndependent:   "INDEPENDENT",
+		ChannelLeftSide:      "LEFT_SIDE",
+		ChannelRightSide:     "RIGHT_SIDE",
+		ChannelMidSide:       "MID_SIDE",
+		ChannelAssignment(9): "ChannelAssignment(9)",
+	}
+	for a, want := range tests {
+		if got := a.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <stdint.h>
+#include <FLAC/format.h>
+
+extern void
+get_md5_signature(FLAC__StreamMetadata *metadata, uint8_t *out);
+*/
+import "C"
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"unsafe"
+)
+
+// MD5Status reports the outcome of MD5 verification (see SetVerifyMD5).
+type MD5Status int
+
+const (
+	// MD5NotChecked: verification is off or no stream is open.
+	MD5NotChecked MD5Status = iota
+
+	// MD5Pending: the stream is being verified; the result is known at
+	// end of stream.
+	MD5Pending
+
+	// MD5Verified: the decoded audio matches the STREAMINFO MD5.
+	MD5Verified
+
+	// MD5Mismatch: the decoded audio differs from what was encoded.
+	MD5Mismatch
+
+	// MD5Missing: STREAMINFO carries no MD5 (all zeros), so the stream
+	// cannot be verified.
+	MD5Missing
+
+	// MD5SeekInvalidated: the stream was seeked, so not all of the audio
+	// was decoded in order and the MD5 cannot be checked.
+	MD5SeekInvalidated
+)
+
+func (s MD5Status) String() string {
+	switch s {
+	case MD5NotChecked:
+		return "not checked"
+	case MD5Pending:
+		return "pending"
+	case MD5Verified:
+		return "verified"
+	case MD5Mismatch:
+		return "mismatch"
+	case MD5Missing:
+		return "no MD5 in STREAMINFO"
+	case MD5SeekInvalidated:
+		return "invalidated by seek"
+	default:
+		return fmt.Sprintf("MD5Status(%d)", int(s))
+	}
+}
+
+// MD5MismatchError is returned at end of stream when MD5 verification is
+// enabled and the decoded audio does not match the STREAMINFO signature.
+type MD5MismatchError struct {
+	Expected [16]byte // signature stored in STREAMINFO
+	Actual   [16]byte // signature of the decoded audio
+}
+
+func (e *MD5MismatchError) Error() string {
+	return fmt.Sprintf("MD5 mismatch: decoded audio has %x, STREAMINFO has %x", e.Actual, e.Expected)
+}
+
+// Is reports whether target is ErrMD5Mismatch.
+func (e *MD5MismatchError) Is(target error) bool {
+	return target == ErrMD5Mismatch
+}
+
+// SetVerifyMD5 enables checking the decoded audio against the MD5
+// signature in STREAMINFO. The signature is computed while decoding, so
+// the result is known as soon as the end of the stream is reached: the
+// DecodeSamples or DecodeFrame call that would return io.EOF returns an
+// *MD5MismatchError instead, and Close returns it if it has not been
+// reported yet. MD5Status reports files without a signature and streams
+// that were seeked, which cannot be verified.
+//
+// Must be called before Open.
+func (d *FlacDecoder) SetVerifyMD5(enable bool) error {
+	if d.channels != 0 {
+		return errors.New("MD5 verification must be set before Open")
+	}
+	d.verifyMD5 = enable
+	return nil
+}
+
+// MD5Status returns the state of MD5 verification for the open stream.
+func (d *FlacDecoder) MD5Status() MD5Status {
+	defer d.lockState()()
+	return d.md5Status
+}
+
+// md5Verifier accumulates the MD5 of the decoded audio in the layout
+// libFLAC uses: interleaved little-endian samples of (bps+7)/8 bytes.
+type md5Verifier struct {
+	expected [16]byte
+	hash     hash.Hash
+	buf      []byte
+	err      error // mismatch not yet returned to the caller
+}
+
+// configureMD5 reads the expected signature for a newly opened stream.
+func (d *FlacDecoder) configureMD5(metadata *C.FLAC__StreamMetadata) {
+	d.md5Status = MD5NotChecked
+	if !d.verifyMD5 {
+		return
+	}
+
+	C.get_md5_signature(metadata, (*C.uint8_t)(unsafe.Pointer(&d.md5.expected[0])))
+	if d.md5.expected == [16]byte{} {
+		d.md5Status = MD5Missing
+		return
+	}
+
+	if d.md5.hash == nil {
+		d.md5.hash = md5.New()
+	}
+	d.md5.hash.Reset()
+	d.md5.err = nil
+	d.md5Status = MD5Pending
+}
+
+// hashFrame adds a frame's samples, per stream channel, to the MD5.
+func (d *FlacDecoder) hashFrame(channels [][]int32, n int) {
+	if d.md5Status != MD5Pending {
+		return
+	}
+
+	bytesPerSample := d.streamBytesPerSample
+	size := n * len(channels) * bytesPerSample
+	if cap(d.md5.buf) < size {
+		d.md5.buf = make([]byte, size)
+	}
+	buf := d.md5.buf[:size]
+
+	stride := len(channels) * bytesPerSample
+	for ch, samples := range channels {
+		off := ch * bytesPerSample
+		for i, v := range samples[:n] {
+			p := buf[i*stride+off : i*stride+off+bytesPerSample]
+			for b := range p {
+				p[b] = byte(v >> (8 * b))
+			}
+		}
+	}
+	d.md5.hash.Write(buf)
+}
+
+// invalidateMD5 records that the audio will not be decoded in order.
+func (d *FlacDecoder) invalidateMD5() {
+	if d.md5Status == MD5Pending {
+		d.md5Status = MD5SeekInvalidated
+	}
+}
+
+// checkMD5 compares the signatures once the whole stream has been
+// decoded and returns the mismatch if it has not been reported yet.
+func (d *FlacDecoder) checkMD5() error {
+	if d.md5Status == MD5Pending {
+		var actual [16]byte
+		d.md5.hash.Sum(actual[:0])
+		if actual == d.md5.expected {
+			d.md5Status = MD5Verified
+		} else {
+			d.md5Status = MD5Mismatch
+			d.md5.err = &MD5MismatchError{Expected: d.md5.expected, Actual: actual}
+		}
+	}
+
+	err := d.md5.err
+	d.md5.err = nil
+	return err
+}
+
+// endOfStream returns the error that ends a stream: the MD5 mismatch the
+// first time, io.EOF otherwise.
+func (d *FlacDecoder) endOfStream() error {
+	if err := d.checkMD5(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// resetMD5 clears the verification state of the current stream.
+func (d *FlacDecoder) resetMD5() {
+	d.md5Status = MD5NotChecked
+	d.md5.expected = [16]byte{}
+	d.md5.err = nil
+}
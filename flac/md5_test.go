@@ -0,0 +1,263 @@
+package flac
+
+import (
+	"crypto/md5"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// streamInfoMD5Offset is where the MD5 signature starts in a file whose
+// first metadata block is STREAMINFO: "fLaC", the block header and 18
+// bytes of stream parameters.
+const streamInfoMD5Offset = 4 + 4 + 18
+
+// patchStreamInfoMD5 writes a copy of path with its STREAMINFO MD5 changed
+// by patch and returns the new path.
+func patchStreamInfoMD5(t *testing.T, path string, patch func(sum []byte)) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data[:4]) != "fLaC" || data[4]&0x7f != 0 {
+		t.Fatal("test file does not start with STREAMINFO")
+	}
+	patch(data[streamInfoMD5Offset : streamInfoMD5Offset+16])
+
+	out := filepath.Join(t.TempDir(), "patched.flac")
+	if err := os.WriteFile(out, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return out
+}
+
+// openVerifyingDecoder opens path with MD5 verification enabled.
+func openVerifyingDecoder(t *testing.T, path string) *FlacDecoder {
+	t.Helper()
+
+	dec, err := NewFlacFrameDecoder(32)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	t.Cleanup(func() { dec.Delete() })
+	if err := dec.SetVerifyMD5(true); err != nil {
+		t.Fatalf("SetVerifyMD5 failed: %v", err)
+	}
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return dec
+}
+
+// drainUntilError decodes until DecodeSamples returns an error.
+func drainUntilError(dec *FlacDecoder) error {
+	buf := make([]byte, chunkSamples*8*4)
+	for {
+		if _, err := dec.DecodeSamples(chunkSamples, buf); err != nil {
+			return err
+		}
+	}
+}
+
+func TestFlacDecoder_VerifyMD5(t *testing.T) {
+	for _, bps := range []int{8, 12, 16, 20, 24} {
+		t.Run(formatTestName(44100, 2, bps), func(t *testing.T) {
+			path, _ := encodeTestFile(t, 44100, 2, bps, 20000)
+			dec := openVerifyingDecoder(t, path)
+
+			if s := dec.MD5Status(); s != MD5Pending {
+				t.Errorf("MD5Status after Open = %v, want %v", s, MD5Pending)
+			}
+			if err := drainUntilError(dec); err != io.EOF {
+				t.Fatalf("DecodeSamples at end = %v, want io.EOF", err)
+			}
+			if s := dec.MD5Status(); s != MD5Verified {
+				t.Errorf("MD5Status = %v, want %v", s, MD5Verified)
+			}
+			if err := dec.Close(); err != nil {
+				t.Errorf("Close failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestFlacDecoder_VerifyMD5Mismatch(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 20000)
+	bad := patchStreamInfoMD5(t, path, func(sum []byte) { sum[0] ^= 0xff })
+
+	dec := openVerifyingDecoder(t, bad)
+	err := drainUntilError(dec)
+	var mismatch *MD5MismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrMD5Mismatch) {
+		t.Fatalf("DecodeSamples at end = %v, want *MD5MismatchError", err)
+	}
+	if mismatch.Expected == mismatch.Actual {
+		t.Error("mismatch reports equal signatures")
+	}
+	if s := dec.MD5Status(); s != MD5Mismatch {
+		t.Errorf("MD5Status = %v, want %v", s, MD5Mismatch)
+	}
+
+	// Reported once, then the stream ends normally.
+	if err := drainUntilError(dec); err != io.EOF {
+		t.Errorf("DecodeSamples after mismatch = %v, want io.EOF", err)
+	}
+	if err := dec.Close(); err != nil {
+		t.Errorf("Close after reported mismatch = %v, want nil", err)
+	}
+}
+
+func TestFlacDecoder_VerifyMD5MismatchOnClose(t *testing.T) {
+	const numSamples = 20000
+	path, _ := encodeTestFile(t, 44100, 1, 16, numSamples)
+	bad := patchStreamInfoMD5(t, path, func(sum []byte) { sum[15] ^= 1 })
+
+	dec := openVerifyingDecoder(t, bad)
+
+	// Read exactly the stream length, so that DecodeSamples never has to
+	// report the end of the stream.
+	buf := make([]byte, numSamples*2)
+	if n, err := dec.DecodeSamples(numSamples, buf); n != numSamples || err != nil {
+		t.Fatalf("DecodeSamples = %d, %v; want %d, nil", n, err, numSamples)
+	}
+	if err := dec.Close(); !errors.Is(err, ErrMD5Mismatch) {
+		t.Errorf("Close = %v, want ErrMD5Mismatch", err)
+	}
+}
+
+func TestFlacDecoder_VerifyMD5Missing(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 5000)
+	unsigned := patchStreamInfoMD5(t, path, func(sum []byte) { clear(sum) })
+
+	dec := openVerifyingDecoder(t, unsigned)
+	if s := dec.MD5Status(); s != MD5Missing {
+		t.Errorf("MD5Status = %v, want %v", s, MD5Missing)
+	}
+	if err := drainUntilError(dec); err != io.EOF {
+		t.Fatalf("DecodeSamples at end = %v, want io.EOF", err)
+	}
+	if s := dec.MD5Status(); s != MD5Missing {
+		t.Errorf("MD5Status at end = %v, want %v", s, MD5Missing)
+	}
+}
+
+func TestFlacDecoder_VerifyMD5Seek(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 20000)
+	bad := patchStreamInfoMD5(t, path, func(sum []byte) { sum[0] ^= 0xff })
+
+	dec := openVerifyingDecoder(t, bad)
+	if _, err := dec.Seek(10000, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if s := dec.MD5Status(); s != MD5SeekInvalidated {
+		t.Errorf("MD5Status after Seek = %v, want %v", s, MD5SeekInvalidated)
+	}
+	if err := drainUntilError(dec); err != io.EOF {
+		t.Fatalf("DecodeSamples at end = %v, want io.EOF", err)
+	}
+	if err := dec.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestFlacDecoder_VerifyMD5DecodeFrame(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 20000)
+	bad := patchStreamInfoMD5(t, path, func(sum []byte) { sum[3] ^= 0x10 })
+
+	dec := openVerifyingDecoder(t, bad)
+	for {
+		_, err := dec.DecodeFrame()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrMD5Mismatch) {
+			t.Fatalf("DecodeFrame at end = %v, want ErrMD5Mismatch", err)
+		}
+		break
+	}
+}
+
+func TestFlacDecoder_VerifyMD5Disabled(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 5000)
+	bad := patchStreamInfoMD5(t, path, func(sum []byte) { sum[0] ^= 0xff })
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(bad); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	if err := drainUntilError(dec); err != io.EOF {
+		t.Fatalf("DecodeSamples at end = %v, want io.EOF", err)
+	}
+	if s := dec.MD5Status(); s != MD5NotChecked {
+		t.Errorf("MD5Status = %v, want %v", s, MD5NotChecked)
+	}
+}
+
+func TestHashFrame_Unit(t *testing.T) {
+	d := &FlacDecoder{streamBytesPerSample: 3, md5Status: MD5Pending}
+	d.md5.hash = md5.New()
+
+	left := []int32{1, -1, 0x7fffff, -0x800000}
+	right := []int32{0x123456, -2, 0, 5}
+	d.hashFrame([][]int32{left, right}, len(left))
+
+	want := md5.Sum([]byte{
+		0x01, 0x00, 0x00, 0x56, 0x34, 0x12,
+		0xff, 0xff, 0xff, 0xfe, 0xff, 0xff,
+		0xff, 0xff, 0x7f, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x80, 0x05, 0x00, 0x00,
+	})
+	var got [16]byte
+	d.md5.hash.Sum(got[:0])
+	if got != want {
+		t.Errorf("hash = %x, want %x", got, want)
+	}
+
+	// Nothing is hashed unless verification is pending.
+	d.md5Status = MD5SeekInvalidated
+	d.hashFrame([][]int32{left, right}, len(left))
+	d.md5.hash.Sum(got[:0])
+	if got != want {
+		t.Error("hashFrame changed the hash after invalidation")
+	}
+}
+
+func TestMD5Status_StringUnit(t *testing.T) {
+	seen := make(map[string]bool)
+	for s := MD5NotChecked; s <= MD5SeekInvalidated; s++ {
+		str := s.String()
+		if strings.HasPrefix(str, "MD5Status(") || seen[str] {
+			t.Errorf("MD5Status(%d) has no distinct name: %q", int(s), str)
+		}
+		seen[str] = true
+	}
+	if got := MD5Status(42).String(); got != "MD5Status(42)" {
+		t.Errorf("String() = %q, want %q", got, "MD5Status(42)")
+	}
+}
+
+func TestSetVerifyMD5_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.SetVerifyMD5(true); err != nil {
+		t.Errorf("SetVerifyMD5 failed: %v", err)
+	}
+	if s := dec.MD5Status(); s != MD5NotChecked {
+		t.Errorf("MD5Status before Open = %v, want %v", s, MD5NotChecked)
+	}
+}
@@ -0,0 +1,579 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <stdlib.h>
+#include <FLAC/metadata.h>
+
+extern FLAC__StreamMetadata_VorbisComment *
+get_vorbis_comment(FLAC__StreamMetadata *metadata);
+
+extern FLAC__StreamMetadata_Application *
+get_application(FLAC__StreamMetadata *metadata);
+
+// vorbiscomment_append copies a comment of length bytes into object.
+static inline FLAC__bool
+vorbiscomment_append(FLAC__StreamMetadata *object, char *entry, uint32_t length)
+{
+    FLAC__StreamMetadata_VorbisComment_Entry e = { length, (FLAC__byte *)entry };
+    return FLAC__metadata_object_vorbiscomment_append_comment(object, e, 1);
+}
+
+// vorbiscomment_set_vendor copies a vendor string of length bytes into
+// object.
+static inline FLAC__bool
+vorbiscomment_set_vendor(FLAC__StreamMetadata *object, char *vendor, uint32_t length)
+{
+    FLAC__StreamMetadata_VorbisComment_Entry e = { length, (FLAC__byte *)vendor };
+    return FLAC__metadata_object_vorbiscomment_set_vendor_string(object, e, 1);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// MetadataType is the type of a metadata block (FLAC__MetadataType).
+type MetadataType int
+
+const (
+	MetadataStreamInfo    MetadataType = iota // STREAMINFO
+	MetadataPadding                           // PADDING
+	MetadataApplication                       // APPLICATION
+	MetadataSeekTable                         // SEEKTABLE
+	MetadataVorbisComment                     // VORBIS_COMMENT
+	MetadataCueSheet                          // CUESHEET
+	MetadataPicture                           // PICTURE
+)
+
+func (t MetadataType) String() string {
+	switch t {
+	case MetadataStreamInfo:
+		return "STREAMINFO"
+	case MetadataPadding:
+		return "PADDING"
+	case MetadataApplication:
+		return "APPLICATION"
+	case MetadataSeekTable:
+		return "SEEKTABLE"
+	case MetadataVorbisComment:
+		return "VORBIS_COMMENT"
+	case MetadataCueSheet:
+		return "CUESHEET"
+	case MetadataPicture:
+		return "PICTURE"
+	default:
+		return fmt.Sprintf("MetadataType(%d)", int(t))
+	}
+}
+
+// MetadataBlock describes a metadata block: its type and the length of
+// its contents in bytes, without the 4-byte block header.
+type MetadataBlock struct {
+	Type   MetadataType
+	Length int
+}
+
+// Application is an APPLICATION metadata block: data owned by the
+// application registered under ID.
+type Application struct {
+	ID   [4]byte
+	Data []byte
+}
+
+// ChainStatus is the status of a metadata chain operation
+// (FLAC__Metadata_ChainStatus).
+type ChainStatus int
+
+func (s ChainStatus) String() string {
+	return cString(unsafe.Pointer(&C.FLAC__Metadata_ChainStatusString),
+		int(C.FLAC__METADATA_CHAIN_STATUS_WRONG_WRITE_CALL)+1, int(s), "ChainStatus")
+}
+
+// MetadataError is a failure of libFLAC's metadata interface.
+type MetadataError struct {
+	Op     string // operation that failed, e.g. "read metadata"
+	Status ChainStatus
+}
+
+func (e *MetadataError) Error() string {
+	return fmt.Sprintf("%s error: %s", e.Op, e.Status)
+}
+
+// MetadataEditor edits the metadata blocks of a FLAC file without
+// re-encoding the audio, using libFLAC's metadata chain. Changes are made
+// in memory and written by Save.
+//
+// Save rewrites only the metadata in place when the new blocks fit, taking
+// or giving space from the last PADDING block. Otherwise libFLAC writes
+// the whole file to a temporary file next to it and renames it over the
+// original, so an interrupted Save never leaves a truncated file behind.
+// InPlace reports which of the two Save will do.
+//
+// Ogg FLAC files can be opened and inspected but not saved.
+//
+// THREAD SAFETY: MetadataEditor is not thread-safe.
+type MetadataEditor struct {
+	chain *C.FLAC__Metadata_Chain
+	it    *C.FLAC__Metadata_Iterator // shared by all block walks
+	ogg   bool
+
+	// Size of the metadata blocks, headers included, as last read or
+	// written; InPlace compares the edited chain against it.
+	fileLength int
+
+	// paddingSet is true after SetPadding until the next Save, which then
+	// writes the padding as given instead of resizing it.
+	paddingSet bool
+}
+
+// OpenMetadataEditor reads the metadata blocks of the FLAC file at path.
+// Close must be called to release the editor.
+func OpenMetadataEditor(path string) (*MetadataEditor, error) {
+	chain := C.FLAC__metadata_chain_new()
+	if chain == nil {
+		return nil, errors.New("failed to create metadata chain")
+	}
+	m := &MetadataEditor{chain: chain, ogg: sniffOggFile(path)}
+	if m.it = C.FLAC__metadata_iterator_new(); m.it == nil {
+		m.Close()
+		return nil, errors.New("failed to create metadata iterator")
+	}
+
+	filename := C.CString(path)
+	defer C.free(unsafe.Pointer(filename))
+
+	var ok C.FLAC__bool
+	if m.ogg {
+		ok = C.FLAC__metadata_chain_read_ogg(chain, filename)
+	} else {
+		ok = C.FLAC__metadata_chain_read(chain, filename)
+	}
+	if ok == 0 {
+		err := m.chainError("read metadata")
+		m.Close()
+		return nil, err
+	}
+	m.fileLength = m.length()
+	return m, nil
+}
+
+// Close releases the editor. Unsaved changes are discarded.
+func (m *MetadataEditor) Close() {
+	if m.it != nil {
+		C.FLAC__metadata_iterator_delete(m.it)
+		m.it = nil
+	}
+	if m.chain != nil {
+		C.FLAC__metadata_chain_delete(m.chain)
+		m.chain = nil
+	}
+}
+
+// Save writes the changes back to the file. See MetadataEditor for how.
+func (m *MetadataEditor) Save() error {
+	if m.chain == nil {
+		return errors.New("metadata editor is closed")
+	}
+	if m.ogg {
+		return errors.New("writing Ogg FLAC metadata is not supported")
+	}
+
+	// Gather the padding at the end, where libFLAC can use it to absorb
+	// changes in size.
+	C.FLAC__metadata_chain_sort_padding(m.chain)
+	usePadding := C.FLAC__bool(1)
+	if m.paddingSet {
+		usePadding = 0
+	}
+	if C.FLAC__metadata_chain_write(m.chain, usePadding, 1) == 0 {
+		return m.chainError("write metadata")
+	}
+	m.fileLength = m.length()
+	m.paddingSet = false
+	return nil
+}
+
+// InPlace reports whether Save can rewrite the metadata in place, without
+// copying the audio to a temporary file. It does not change the blocks.
+func (m *MetadataEditor) InPlace() bool {
+	if m.chain == nil || m.ogg {
+		return false
+	}
+
+	// This is FLAC__metadata_chain_check_if_tempfile_needed applied to the
+	// chain as Save leaves it after sorting: all padding merged into one
+	// block at the end, which keeps the total length.
+	length := m.length()
+	if m.paddingSet {
+		return length == m.fileLength
+	}
+	padding, paddingBlocks := 0, 0
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		if block._type == C.FLAC__METADATA_TYPE_PADDING {
+			padding += int(block.length)
+			paddingBlocks++
+		}
+	})
+	if paddingBlocks > 1 {
+		padding += (paddingBlocks - 1) * metadataHeaderLength
+	}
+
+	switch {
+	case length < m.fileLength && paddingBlocks > 0:
+		// The padding grows to fill the space.
+		return true
+	case length+metadataHeaderLength <= m.fileLength:
+		// A new padding block fills the space.
+		return true
+	case length > m.fileLength && paddingBlocks > 0:
+		// The padding shrinks or goes away to make room.
+		delta := length - m.fileLength
+		return padding+metadataHeaderLength == delta || padding >= delta
+	}
+	return length == m.fileLength
+}
+
+// length returns the size of the metadata blocks, headers included.
+func (m *MetadataEditor) length() int {
+	n := 0
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		n += metadataHeaderLength + int(block.length)
+	})
+	return n
+}
+
+// Blocks lists the metadata blocks in file order.
+func (m *MetadataEditor) Blocks() []MetadataBlock {
+	var blocks []MetadataBlock
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		blocks = append(blocks, MetadataBlock{Type: MetadataType(block._type), Length: int(block.length)})
+	})
+	return blocks
+}
+
+// Tags returns the Vorbis comments, or nil if there is no VORBIS_COMMENT
+// block.
+func (m *MetadataEditor) Tags() *Tags {
+	var tags *Tags
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		if tags == nil && block._type == C.FLAC__METADATA_TYPE_VORBIS_COMMENT {
+			tags = parseVorbisComment(block)
+		}
+	})
+	return tags
+}
+
+// SetTags replaces the Vorbis comments with tags; nil removes the
+// VORBIS_COMMENT block. An empty Vendor keeps the existing vendor string.
+func (m *MetadataEditor) SetTags(tags *Tags) error {
+	if m.chain == nil {
+		return errors.New("metadata editor is closed")
+	}
+
+	var obj *C.FLAC__StreamMetadata
+	if tags != nil {
+		t := *tags
+		if t.Vendor == "" {
+			if old := m.Tags(); old != nil {
+				t.Vendor = old.Vendor
+			}
+		}
+		var err error
+		if obj, err = newVorbisCommentObject(&t); err != nil {
+			return err
+		}
+	}
+
+	m.removeBlocks(func(block *C.FLAC__StreamMetadata) bool {
+		return block._type == C.FLAC__METADATA_TYPE_VORBIS_COMMENT
+	})
+	if obj == nil {
+		return nil
+	}
+	return m.insertBlock(obj)
+}
+
+// Pictures returns the embedded pictures in file order.
+func (m *MetadataEditor) Pictures() []*Picture {
+	var pictures []*Picture
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		if block._type == C.FLAC__METADATA_TYPE_PICTURE {
+			pictures = append(pictures, parsePicture(block))
+		}
+	})
+	return pictures
+}
+
+// AddPicture adds a PICTURE block after the existing ones.
+func (m *MetadataEditor) AddPicture(pic *Picture) error {
+	if m.chain == nil {
+		return errors.New("metadata editor is closed")
+	}
+	obj, err := newPictureObject(pic)
+	if err != nil {
+		return err
+	}
+	return m.insertBlock(obj)
+}
+
+// RemovePictures removes the pictures for which match returns true, or
+// all of them if match is nil. It returns the number removed.
+func (m *MetadataEditor) RemovePictures(match func(*Picture) bool) int {
+	return m.removeBlocks(func(block *C.FLAC__StreamMetadata) bool {
+		return block._type == C.FLAC__METADATA_TYPE_PICTURE && (match == nil || match(parsePicture(block)))
+	})
+}
+
+// Applications returns the APPLICATION blocks in file order.
+func (m *MetadataEditor) Applications() []Application {
+	var apps []Application
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		if block._type == C.FLAC__METADATA_TYPE_APPLICATION {
+			apps = append(apps, parseApplication(block))
+		}
+	})
+	return apps
+}
+
+// SetApplication stores data in the APPLICATION block for id, replacing
+// any existing blocks with that id.
+func (m *MetadataEditor) SetApplication(id [4]byte, data []byte) error {
+	if m.chain == nil {
+		return errors.New("metadata editor is closed")
+	}
+	obj, err := newApplicationObject(Application{ID: id, Data: data})
+	if err != nil {
+		return err
+	}
+	m.RemoveApplication(id)
+	return m.insertBlock(obj)
+}
+
+// RemoveApplication removes the APPLICATION blocks for id and reports
+// whether there were any.
+func (m *MetadataEditor) RemoveApplication(id [4]byte) bool {
+	return m.removeBlocks(func(block *C.FLAC__StreamMetadata) bool {
+		return block._type == C.FLAC__METADATA_TYPE_APPLICATION && parseApplication(block).ID == id
+	}) > 0
+}
+
+// Padding returns the total size of the PADDING blocks in bytes, without
+// their headers.
+func (m *MetadataEditor) Padding() int {
+	total := 0
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		if block._type == C.FLAC__METADATA_TYPE_PADDING {
+			total += int(block.length)
+		}
+	})
+	return total
+}
+
+// SetPadding replaces all padding with a single PADDING block of n bytes
+// at the end of the metadata; 0 removes the padding. The next Save writes
+// exactly this padding, rewriting the file if its size changes; later
+// saves resize the padding again to avoid rewrites.
+func (m *MetadataEditor) SetPadding(n int) error {
+	if m.chain == nil {
+		return errors.New("metadata editor is closed")
+	}
+	if n < 0 || n > maxMetadataBlockLength {
+		return fmt.Errorf("invalid padding: %d (must be 0-%d)", n, maxMetadataBlockLength)
+	}
+
+	m.removeBlocks(func(block *C.FLAC__StreamMetadata) bool {
+		return block._type == C.FLAC__METADATA_TYPE_PADDING
+	})
+	m.paddingSet = true
+	if n == 0 {
+		return nil
+	}
+
+	obj, err := newPaddingObject(n)
+	if err != nil {
+		return err
+	}
+	it := m.iterator()
+	for C.FLAC__metadata_iterator_next(it) != 0 {
+	}
+	if C.FLAC__metadata_iterator_insert_block_after(it, obj) == 0 {
+		C.FLAC__metadata_object_delete(obj)
+		return m.chainError("insert PADDING block")
+	}
+	return nil
+}
+
+const (
+	// metadataHeaderLength is the size of a metadata block header.
+	metadataHeaderLength = 4
+
+	// maxMetadataBlockLength is the largest block the 24-bit length field
+	// in a block header can describe.
+	maxMetadataBlockLength = 1<<24 - 1
+)
+
+// chainError returns a *MetadataError for op with the chain's status.
+func (m *MetadataEditor) chainError(op string) error {
+	return &MetadataError{Op: op, Status: ChainStatus(C.FLAC__metadata_chain_status(m.chain))}
+}
+
+// iterator moves the editor's iterator to the first block and returns it.
+// Walks do not nest, so one iterator serves them all.
+func (m *MetadataEditor) iterator() *C.FLAC__Metadata_Iterator {
+	C.FLAC__metadata_iterator_init(m.it, m.chain)
+	return m.it
+}
+
+// each calls fn for every block in order.
+func (m *MetadataEditor) each(fn func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata)) {
+	if m.chain == nil {
+		return
+	}
+	it := m.iterator()
+	for {
+		fn(it, C.FLAC__metadata_iterator_get_block(it))
+		if C.FLAC__metadata_iterator_next(it) == 0 {
+			return
+		}
+	}
+}
+
+// removeBlocks deletes the blocks for which match returns true and
+// returns the number deleted. STREAMINFO is never deleted.
+func (m *MetadataEditor) removeBlocks(match func(block *C.FLAC__StreamMetadata) bool) int {
+	n := 0
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		if block._type != C.FLAC__METADATA_TYPE_STREAMINFO && match(block) {
+			// The iterator moves back to the previous block, so the
+			// loop continues with the one after the deleted block.
+			C.FLAC__metadata_iterator_delete_block(it, 0)
+			n++
+		}
+	})
+	return n
+}
+
+// insertBlock adds obj after the last block that is not padding, taking
+// ownership of it.
+func (m *MetadataEditor) insertBlock(obj *C.FLAC__StreamMetadata) error {
+	it := m.iterator()
+	for C.FLAC__metadata_iterator_next(it) != 0 {
+	}
+	for C.FLAC__metadata_iterator_get_block_type(it) == C.FLAC__METADATA_TYPE_PADDING {
+		if C.FLAC__metadata_iterator_prev(it) == 0 {
+			break
+		}
+	}
+	if C.FLAC__metadata_iterator_insert_block_after(it, obj) == 0 {
+		C.FLAC__metadata_object_delete(obj)
+		return m.chainError(fmt.Sprintf("insert %s block", MetadataType(obj._type)))
+	}
+	return nil
+}
+
+// parseApplication copies an APPLICATION block into Go memory.
+func parseApplication(metadata *C.FLAC__StreamMetadata) Application {
+	a := C.get_application(metadata)
+	app := Application{}
+	for i := range app.ID {
+		app.ID[i] = byte(a.id[i])
+	}
+	if n := int(metadata.length) - len(app.ID); n > 0 && a.data != nil {
+		app.Data = C.GoBytes(unsafe.Pointer(a.data), C.int(n))
+	}
+	return app
+}
+
+// newApplicationObject builds an APPLICATION block. The caller owns the
+// result, as for newPictureObject.
+func newApplicationObject(app Application) (*C.FLAC__StreamMetadata, error) {
+	if len(app.Data) > maxMetadataBlockLength-len(app.ID) {
+		return nil, fmt.Errorf("application data too large: %d bytes", len(app.Data))
+	}
+
+	obj := C.FLAC__metadata_object_new(C.FLAC__METADATA_TYPE_APPLICATION)
+	if obj == nil {
+		return nil, errors.New("failed to allocate APPLICATION block")
+	}
+	a := C.get_application(obj)
+	for i, b := range app.ID {
+		a.id[i] = C.FLAC__byte(b)
+	}
+	if len(app.Data) > 0 {
+		data := C.CBytes(app.Data)
+		defer C.free(data)
+		if C.FLAC__metadata_object_application_set_data(obj, (*C.FLAC__byte)(data), C.uint32_t(len(app.Data)), 1) == 0 {
+			C.FLAC__metadata_object_delete(obj)
+			return nil, errors.New("failed to allocate APPLICATION block")
+		}
+	}
+	return obj, nil
+}
+
+// newPaddingObject builds a PADDING block of n bytes.
+func newPaddingObject(n int) (*C.FLAC__StreamMetadata, error) {
+	obj := C.FLAC__metadata_object_new(C.FLAC__METADATA_TYPE_PADDING)
+	if obj == nil {
+		return nil, errors.New("failed to allocate PADDING block")
+	}
+	obj.length = C.uint32_t(n)
+	return obj, nil
+}
+
+// newVorbisCommentObject builds a VORBIS_COMMENT block from tags. An empty
+// vendor string is replaced by libFLAC's when the block is written by the
+// encoder. The caller owns the result, as for newPictureObject.
+func newVorbisCommentObject(tags *Tags) (*C.FLAC__StreamMetadata, error) {
+	for _, f := range tags.Fields {
+		if err := validTagName(f.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	obj := C.FLAC__metadata_object_new(C.FLAC__METADATA_TYPE_VORBIS_COMMENT)
+	if obj == nil {
+		return nil, errors.New("failed to allocate VORBIS_COMMENT block")
+	}
+
+	ok := withCString(tags.Vendor, func(s *C.char, n C.uint32_t) bool {
+		return C.vorbiscomment_set_vendor(obj, s, n) != 0
+	})
+	for _, f := range tags.Fields {
+		if !ok {
+			break
+		}
+		ok = withCString(f.Name+"="+f.Value, func(s *C.char, n C.uint32_t) bool {
+			return C.vorbiscomment_append(obj, s, n) != 0
+		})
+	}
+	if !ok {
+		C.FLAC__metadata_object_delete(obj)
+		return nil, errors.New("failed to allocate VORBIS_COMMENT block")
+	}
+	return obj, nil
+}
+
+// withCString calls fn with a C copy of s and its length in bytes.
+func withCString(s string, fn func(*C.char, C.uint32_t) bool) bool {
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	return fn(cs, C.uint32_t(len(s)))
+}
+
+// validTagName checks a Vorbis comment field name: printable ASCII
+// (0x20-0x7D) other than '=', and not empty.
+func validTagName(name string) error {
+	if name == "" {
+		return errors.New("invalid tag name: empty")
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < 0x20 || c > 0x7d || c == '=' {
+			return fmt.Errorf("invalid tag name %q: character %q not allowed", name, c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package flac
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func openTestEditor(t *testing.T, path string) *MetadataEditor {
+	t.Helper()
+
+	m, err := OpenMetadataEditor(path)
+	if err != nil {
+		t.Fatalf("OpenMetadataEditor failed: %v", err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestMetadataEditor_Edit(t *testing.T) {
+	path, samples := encodeTestFile(t, 44100, 2, 16, 5000)
+
+	m := openTestEditor(t, path)
+	vendor := m.Tags().Vendor
+	if vendor == "" {
+		t.Fatal("encoded file has no vendor string")
+	}
+
+	tags := &Tags{Fields: []TagField{{"ARTIST", "Someone"}, {"TITLE", "Ünïcödé"}, {"ARTIST", "Someone Else"}}}
+	if err := m.SetTags(tags); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	cover := &Picture{
+		Type:        PictureFrontCover,
+		MIMEType:    "image/png",
+		Description: "front",
+		Width:       1,
+		Height:      1,
+		Depth:       24,
+		Data:        []byte("\x89PNG\r\n\x1a\nnot really a png"),
+	}
+	if err := m.AddPicture(cover); err != nil {
+		t.Fatalf("AddPicture failed: %v", err)
+	}
+	if err := m.AddPicture(&Picture{Type: PictureBackCover, MIMEType: "image/jpeg", Data: []byte{0xff, 0xd8}}); err != nil {
+		t.Fatalf("AddPicture failed: %v", err)
+	}
+	id := [4]byte{'t', 'e', 's', 't'}
+	if err := m.SetApplication(id, []byte("old")); err != nil {
+		t.Fatalf("SetApplication failed: %v", err)
+	}
+	if err := m.SetApplication(id, []byte("payload")); err != nil {
+		t.Fatalf("SetApplication failed: %v", err)
+	}
+	if err := m.SetPadding(1024); err != nil {
+		t.Fatalf("SetPadding failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	m.Close()
+
+	m = openTestEditor(t, path)
+	got := m.Tags()
+	if got.Vendor != vendor {
+		t.Errorf("Vendor = %q, want %q", got.Vendor, vendor)
+	}
+	if !slices.Equal(got.Fields, tags.Fields) {
+		t.Errorf("Fields = %q, want %q", got.Fields, tags.Fields)
+	}
+	pics := m.Pictures()
+	if len(pics) != 2 {
+		t.Fatalf("got %d pictures, want 2", len(pics))
+	}
+	if p := pics[0]; p.Type != cover.Type || p.MIMEType != cover.MIMEType || p.Description != cover.Description ||
+		p.Width != 1 || p.Height != 1 || p.Depth != 24 || !bytes.Equal(p.Data, cover.Data) {
+		t.Errorf("picture = %+v, want %+v", p, cover)
+	}
+	apps := m.Applications()
+	if len(apps) != 1 || apps[0].ID != id || string(apps[0].Data) != "payload" {
+		t.Errorf("Applications() = %+v", apps)
+	}
+	if got := m.Padding(); got != 1024 {
+		t.Errorf("Padding() = %d, want 1024", got)
+	}
+	blocks := m.Blocks()
+	if blocks[0].Type != MetadataStreamInfo || blocks[len(blocks)-1].Type != MetadataPadding {
+		t.Errorf("Blocks() = %v, want STREAMINFO first and PADDING last", blocks)
+	}
+
+	// The audio is untouched.
+	if decoded, _ := decodeInt32All(t, path, nil); !slices.Equal(decoded, samples) {
+		t.Error("decoded samples differ after editing the metadata")
+	}
+
+	// Removal.
+	if n := m.RemovePictures(func(p *Picture) bool { return p.Type == PictureBackCover }); n != 1 {
+		t.Errorf("RemovePictures removed %d, want 1", n)
+	}
+	if !m.RemoveApplication(id) || m.RemoveApplication(id) {
+		t.Error("RemoveApplication reported the wrong result")
+	}
+	if err := m.SetTags(nil); err != nil {
+		t.Fatalf("SetTags(nil) failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	m.Close()
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+	if dec.Tags() != nil {
+		t.Errorf("Tags() = %+v after removing the VORBIS_COMMENT block", dec.Tags())
+	}
+
+	m = openTestEditor(t, path)
+	if pics := m.Pictures(); len(pics) != 1 || pics[0].Type != PictureFrontCover {
+		t.Errorf("Pictures() = %+v, want only the front cover", pics)
+	}
+}
+
+func TestMetadataEditor_InPlace(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 1, 16, 5000)
+
+	m := openTestEditor(t, path)
+	if err := m.SetPadding(4096); err != nil {
+		t.Fatalf("SetPadding failed: %v", err)
+	}
+	if m.InPlace() {
+		t.Error("InPlace() = true for a change that grows the file")
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	m.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	// The requested padding is written even though the file had none.
+	m = openTestEditor(t, path)
+	if got := m.Padding(); got != 4096 {
+		t.Errorf("Padding() = %d, want 4096", got)
+	}
+	if err := m.SetTags(&Tags{Fields: []TagField{{"TITLE", "fits in the padding"}}}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	if !m.InPlace() {
+		t.Error("InPlace() = false for a change that fits in the padding")
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	m.Close()
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if after.Size() != info.Size() {
+		t.Errorf("file size changed from %d to %d on an in-place save", info.Size(), after.Size())
+	}
+	m = openTestEditor(t, path)
+	if got := m.Tags().Get("TITLE"); got != "fits in the padding" {
+		t.Errorf("TITLE = %q", got)
+	}
+	if m.Padding() >= 4096 {
+		t.Errorf("Padding() = %d, want it reduced by the new tags", m.Padding())
+	}
+}
+
+func TestMetadataEditor_InPlaceKeepsBlocks(t *testing.T) {
+	// Padding right after STREAMINFO, where libFLAC cannot use it until
+	// Save sorts it to the end.
+	path, _ := encodeTestFile(t, 44100, 1, 16, 5000)
+	path = replaceMetadataBlock(t, path, 1, make([]byte, 512))
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	m := openTestEditor(t, path)
+	if err := m.SetTags(&Tags{Fields: []TagField{{"TITLE", "short"}}}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	before := m.Blocks()
+	if before[1].Type != MetadataPadding {
+		t.Fatalf("Blocks() = %v, want PADDING second", before)
+	}
+	if !m.InPlace() {
+		t.Error("InPlace() = false for a change that fits in the padding")
+	}
+	if after := m.Blocks(); !slices.Equal(after, before) {
+		t.Errorf("InPlace changed the blocks from %v to %v", before, after)
+	}
+
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if after.Size() != info.Size() {
+		t.Errorf("file size changed from %d to %d on an in-place save", info.Size(), after.Size())
+	}
+	if blocks := m.Blocks(); blocks[len(blocks)-1].Type != MetadataPadding {
+		t.Errorf("Blocks() after Save = %v, want PADDING last", blocks)
+	}
+}
+
+func TestMetadataEditor_OpenNonExistent(t *testing.T) {
+	_, err := OpenMetadataEditor("/nonexistent/file.flac")
+	var metaErr *MetadataError
+	if !errors.As(err, &metaErr) {
+		t.Fatalf("error = %v, want *MetadataError", err)
+	}
+	if got, want := metaErr.Status.String(), "FLAC__METADATA_CHAIN_STATUS_ERROR_OPENING_FILE"; got != want {
+		t.Errorf("Status = %q, want %q", got, want)
+	}
+}
+
+func TestMetadataEditor_InvalidInput(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 1, 16, 5000)
+	m := openTestEditor(t, path)
+
+	for _, name := range []string{"", "A=B", "TAB\tNAME", "~TILDE", "NÄME"} {
+		if err := m.SetTags(&Tags{Fields: []TagField{{name, "x"}}}); err == nil {
+			t.Errorf("SetTags accepted field name %q", name)
+		}
+	}
+	if err := m.AddPicture(&Picture{Type: 21, MIMEType: "image/png"}); err == nil {
+		t.Error("AddPicture accepted picture type 21")
+	}
+	if err := m.SetPadding(-1); err == nil {
+		t.Error("SetPadding accepted -1")
+	}
+
+	m.Close()
+	if err := m.Save(); err == nil {
+		t.Error("Save after Close succeeded")
+	}
+}
+
+func TestValidTagName_Unit(t *testing.T) {
+	for _, name := range []string{"ARTIST", "musicbrainz_trackid", "REPLAYGAIN TRACK GAIN", " !}"} {
+		if err := validTagName(name); err != nil {
+			t.Errorf("validTagName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "A=B", "\x1f", "~", "\x7f", "É"} {
+		if err := validTagName(name); err == nil {
+			t.Errorf("validTagName(%q) accepted", name)
+		}
+	}
+}
+
+func TestMetadataStrings_Unit(t *testing.T) {
+	if got := MetadataVorbisComment.String(); got != "VORBIS_COMMENT" {
+		t.Errorf("MetadataVorbisComment = %q", got)
+	}
+	if got := MetadataType(126).String(); got != "MetadataType(126)" {
+		t.Errorf("MetadataType(126) = %q", got)
+	}
+	if got := ChainStatus(-1).String(); got != "ChainStatus(-1)" {
+		t.Errorf("ChainStatus(-1) = %q", got)
+	}
+	if got := PictureType(21).String(); got != "PictureType(21)" {
+		t.Errorf("PictureType(21) = %q", got)
+	}
+}
@@ -0,0 +1,200 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// pcmReaderChunkSamples is the number of samples (per channel) a PCMReader
+// decodes at a time.
+const pcmReaderChunkSamples = 4096
+
+// PCMReader exposes a decoder's output as a stream of interleaved
+// little-endian PCM bytes, in the format reported by GetFormat.
+//
+// It implements io.Reader, io.WriterTo and io.Seeker, with positions in
+// bytes, so it can be passed to io.Copy, http.ServeContent and similar.
+// Reads may end in the middle of a sample; the remainder is returned by
+// the next Read.
+//
+// The decoder must be open before the PCMReader is used, and should not be
+// read or seeked directly while the PCMReader is in use.
+type PCMReader struct {
+	dec *FlacDecoder
+
+	buf     []byte
+	pending []byte // decoded bytes not yet returned
+	pos     int64  // byte position of the next byte returned
+	eof     bool
+	err     error // decode error, reported once pending data is drained
+}
+
+var (
+	_ io.ReadSeeker = (*PCMReader)(nil)
+	_ io.WriterTo   = (*PCMReader)(nil)
+)
+
+// NewPCMReader returns a PCMReader reading from dec, starting at the
+// decoder's current position.
+func NewPCMReader(dec *FlacDecoder) *PCMReader {
+	r := &PCMReader{dec: dec}
+	if fb := dec.frameBytes(); fb > 0 {
+		r.pos = dec.TellCurrentSample() * int64(fb)
+	}
+	return r
+}
+
+// Read reads up to len(p) bytes of PCM data. It returns io.EOF at the end
+// of the stream.
+func (r *PCMReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.pending) == 0 {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	r.pos += int64(n)
+	return n, nil
+}
+
+// WriteTo writes the remaining PCM data to w until the end of the stream
+// or an error occurs. It returns the number of bytes written.
+func (r *PCMReader) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for {
+		if len(r.pending) == 0 {
+			if err := r.fill(); err != nil {
+				if err == io.EOF {
+					return written, nil
+				}
+				return written, err
+			}
+		}
+
+		n, err := w.Write(r.pending)
+		r.pending = r.pending[n:]
+		r.pos += int64(n)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		if len(r.pending) > 0 {
+			return written, io.ErrShortWrite
+		}
+	}
+}
+
+// Seek sets the byte position for the next Read, relative to the start of
+// the PCM data, the current position or the end of the stream (which
+// requires TotalSamples to be known). Positions need not fall on a sample
+// boundary. Seeking to or past the end is allowed; reads there return
+// io.EOF.
+func (r *PCMReader) Seek(offset int64, whence int) (int64, error) {
+	fb := int64(r.dec.frameBytes())
+	if fb == 0 {
+		return r.pos, errors.New("decoder not initialized: no stream open")
+	}
+	total := r.dec.TotalSamples() * fb
+
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = r.pos + offset
+	case io.SeekEnd:
+		if total == 0 {
+			return r.pos, errors.New("cannot seek relative to end: stream length unknown")
+		}
+		target = total + offset
+	default:
+		return r.pos, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if target < 0 {
+		return r.pos, errors.New("cannot seek before start of stream")
+	}
+
+	if total > 0 && target >= total {
+		r.pending = nil
+		r.eof = true
+		r.err = nil
+		r.pos = target
+		return r.pos, nil
+	}
+
+	if _, err := r.dec.Seek(target/fb, io.SeekStart); err != nil {
+		return r.pos, err
+	}
+	r.pending = nil
+	r.eof = false
+	r.err = nil
+	r.pos = target - target%fb
+
+	// Drop the leading bytes of the first sample for unaligned targets.
+	if skip := target % fb; skip > 0 {
+		if err := r.fill(); err != nil && err != io.EOF {
+			return r.pos, err
+		}
+		if int64(len(r.pending)) < skip {
+			skip = int64(len(r.pending))
+		}
+		r.pending = r.pending[skip:]
+		r.pos += skip
+	}
+
+	return r.pos, nil
+}
+
+// fill decodes the next chunk into r.pending. It returns io.EOF once the
+// stream is exhausted.
+func (r *PCMReader) fill() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.eof {
+		return io.EOF
+	}
+
+	fb := r.dec.frameBytes()
+	if fb == 0 {
+		return errors.New("decoder not initialized: no stream open")
+	}
+	if len(r.buf) != pcmReaderChunkSamples*fb {
+		r.buf = make([]byte, pcmReaderChunkSamples*fb)
+	}
+
+	n, err := r.dec.DecodeSamples(pcmReaderChunkSamples, r.buf)
+	r.pending = r.buf[:n*fb]
+	if err == io.EOF {
+		r.eof = true
+		if n == 0 {
+			return io.EOF
+		}
+		return nil
+	}
+	if err != nil {
+		// Return what was decoded first; the error follows.
+		r.err = err
+		if n > 0 {
+			return nil
+		}
+		return err
+	}
+	if n == 0 {
+		r.eof = true
+		return io.EOF
+	}
+	return nil
+}
+
+// frameBytes returns the size in bytes of one interleaved sample frame in
+// the output format, or 0 if no stream is open.
+func (d *FlacDecoder) frameBytes() int {
+	return d.channels * d.outputBytesPerSample
+}
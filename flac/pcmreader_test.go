@@ -0,0 +1,153 @@
+package flac
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// openTestDecoder encodes a test file and opens a decoder on it.
+func openTestDecoder(t *testing.T, rate, channels, bps, numSamples int) *FlacDecoder {
+	t.Helper()
+
+	path, _ := encodeTestFile(t, rate, channels, bps, numSamples)
+
+	dec, err := NewFlacFrameDecoder(32)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	t.Cleanup(func() { dec.Delete() })
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { dec.Close() })
+	return dec
+}
+
+// expectedPCM decodes a fresh copy of the stream with DecodeSamples.
+func expectedPCM(t *testing.T, rate, channels, bps, numSamples int) []byte {
+	t.Helper()
+	return decodeAllBytes(t, openTestDecoder(t, rate, channels, bps, numSamples))
+}
+
+func TestPCMReader_Copy(t *testing.T) {
+	want := expectedPCM(t, 44100, 2, 24, 10001)
+
+	r := NewPCMReader(openTestDecoder(t, 44100, 2, 24, 10001))
+	var got bytes.Buffer
+	n, err := io.Copy(&got, r)
+	if err != nil {
+		t.Fatalf("io.Copy failed: %v", err)
+	}
+	if n != int64(len(want)) || !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("io.Copy wrote %d bytes, want %d (equal: %v)", n, len(want), bytes.Equal(got.Bytes(), want))
+	}
+
+	// Further reads report EOF.
+	if _, err := r.Read(make([]byte, 16)); err != io.EOF {
+		t.Errorf("Read after end = %v, want io.EOF", err)
+	}
+}
+
+func TestPCMReader_OddReadSizes(t *testing.T) {
+	want := expectedPCM(t, 48000, 2, 24, 5000)
+
+	// One-byte and 7-byte reads split 6-byte sample frames at every offset.
+	r := NewPCMReader(openTestDecoder(t, 48000, 2, 24, 5000))
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("one-byte reads: got %d bytes, want %d", len(got), len(want))
+	}
+
+	r = NewPCMReader(openTestDecoder(t, 48000, 2, 24, 5000))
+	var out []byte
+	buf := make([]byte, 7)
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+	if !bytes.Equal(out, want) {
+		t.Fatalf("7-byte reads: got %d bytes, want %d", len(out), len(want))
+	}
+}
+
+func TestPCMReader_Seek(t *testing.T) {
+	want := expectedPCM(t, 44100, 2, 16, 20000)
+	r := NewPCMReader(openTestDecoder(t, 44100, 2, 16, 20000))
+
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatalf("Seek to end failed: %v", err)
+	}
+	if end != int64(len(want)) {
+		t.Fatalf("Seek(0, SeekEnd) = %d, want %d", end, len(want))
+	}
+	if _, err := r.Read(make([]byte, 4)); err != io.EOF {
+		t.Errorf("Read at end = %v, want io.EOF", err)
+	}
+
+	// Unaligned offset in the middle of a sample
+	for _, off := range []int64{0, 3, 40001, int64(len(want)) - 5} {
+		pos, err := r.Seek(off, io.SeekStart)
+		if err != nil {
+			t.Fatalf("Seek(%d) failed: %v", off, err)
+		}
+		if pos != off {
+			t.Fatalf("Seek(%d) returned %d", off, pos)
+		}
+		got := make([]byte, 5)
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("ReadFull after Seek(%d) failed: %v", off, err)
+		}
+		if !bytes.Equal(got, want[off:off+5]) {
+			t.Fatalf("bytes at %d = %v, want %v", off, got, want[off:off+5])
+		}
+	}
+
+	// Relative seek
+	pos, err := r.Seek(-10, io.SeekCurrent)
+	if err != nil {
+		t.Fatalf("Seek(-10, SeekCurrent) failed: %v", err)
+	}
+	if want := int64(len(want)) - 10; pos != want {
+		t.Errorf("Seek(-10, SeekCurrent) = %d, want %d", pos, want)
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek to negative position should fail")
+	}
+}
+
+func TestPCMReader_IOTest(t *testing.T) {
+	want := expectedPCM(t, 22050, 1, 16, 9000)
+	r := NewPCMReader(openTestDecoder(t, 22050, 1, 16, 9000))
+	if err := iotest.TestReader(r, want); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPCMReader_NotOpenValidation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	r := NewPCMReader(dec)
+	if _, err := r.Read(make([]byte, 4)); err == nil || err == io.EOF {
+		t.Errorf("Read on unopened decoder = %v, want an error", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err == nil {
+		t.Error("Seek on unopened decoder should fail")
+	}
+}
@@ -0,0 +1,149 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <stdlib.h>
+#include <FLAC/metadata.h>
+
+extern FLAC__StreamMetadata_Picture *
+get_picture(FLAC__StreamMetadata *metadata);
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// PictureType is the role of an embedded picture, as in ID3v2 APIC frames
+// (FLAC__StreamMetadata_Picture_Type).
+type PictureType int
+
+const (
+	PictureOther              PictureType = iota // other
+	PictureFileIconStandard                      // 32x32 PNG file icon
+	PictureFileIcon                              // other file icon
+	PictureFrontCover                            // cover (front)
+	PictureBackCover                             // cover (back)
+	PictureLeafletPage                           // leaflet page
+	PictureMedia                                 // media, e.g. label side of a CD
+	PictureLeadArtist                            // lead artist, performer or soloist
+	PictureArtist                                // artist or performer
+	PictureConductor                             // conductor
+	PictureBand                                  // band or orchestra
+	PictureComposer                              // composer
+	PictureLyricist                              // lyricist or text writer
+	PictureRecordingLocation                     // recording location
+	PictureDuringRecording                       // during recording
+	PictureDuringPerformance                     // during performance
+	PictureVideoScreenCapture                    // movie or video screen capture
+	PictureFish                                  // a bright colored fish
+	PictureIllustration                          // illustration
+	PictureBandLogo                              // band or artist logotype
+	PicturePublisherLogo                         // publisher or studio logotype
+)
+
+// String returns libFLAC's name for the picture type, e.g.
+// "Cover (front)".
+func (t PictureType) String() string {
+	return cString(unsafe.Pointer(&C.FLAC__StreamMetadata_Picture_TypeString),
+		int(C.FLAC__STREAM_METADATA_PICTURE_TYPE_UNDEFINED), int(t), "PictureType")
+}
+
+// Picture is an image embedded in a PICTURE metadata block, typically
+// cover art.
+type Picture struct {
+	Type        PictureType
+	MIMEType    string // e.g. "image/jpeg"; "-->" means Data is a URL
+	Description string
+	Width       uint32 // in pixels
+	Height      uint32 // in pixels
+	Depth       uint32 // color depth in bits per pixel
+	Colors      uint32 // number of colors for indexed images, 0 otherwise
+	Data        []byte
+}
+
+// parsePicture copies a PICTURE block into Go memory.
+func parsePicture(metadata *C.FLAC__StreamMetadata) *Picture {
+	p := C.get_picture(metadata)
+	pic := &Picture{
+		Type:   PictureType(p._type),
+		Width:  uint32(p.width),
+		Height: uint32(p.height),
+		Depth:  uint32(p.depth),
+		Colors: uint32(p.colors),
+	}
+	if p.mime_type != nil {
+		pic.MIMEType = C.GoString(p.mime_type)
+	}
+	if p.description != nil {
+		pic.Description = C.GoString((*C.char)(unsafe.Pointer(p.description)))
+	}
+	if p.data_length > 0 && p.data != nil {
+		pic.Data = C.GoBytes(unsafe.Pointer(p.data), C.int(p.data_length))
+	}
+	return pic
+}
+
+// newPictureObject builds a PICTURE block for pic. The caller owns the
+// result and must pass it to libFLAC or free it with
+// FLAC__metadata_object_delete.
+func newPictureObject(pic *Picture) (*C.FLAC__StreamMetadata, error) {
+	if pic.Type < PictureOther || pic.Type > PicturePublisherLogo {
+		return nil, fmt.Errorf("invalid picture type: %d", int(pic.Type))
+	}
+
+	obj := C.FLAC__metadata_object_new(C.FLAC__METADATA_TYPE_PICTURE)
+	if obj == nil {
+		return nil, errors.New("failed to allocate PICTURE block")
+	}
+
+	p := C.get_picture(obj)
+	p._type = C.FLAC__StreamMetadata_Picture_Type(pic.Type)
+	p.width = C.FLAC__uint32(pic.Width)
+	p.height = C.FLAC__uint32(pic.Height)
+	p.depth = C.FLAC__uint32(pic.Depth)
+	p.colors = C.FLAC__uint32(pic.Colors)
+
+	// The setters copy their arguments.
+	mime := C.CString(pic.MIMEType)
+	defer C.free(unsafe.Pointer(mime))
+	desc := C.CString(pic.Description)
+	defer C.free(unsafe.Pointer(desc))
+	ok := C.FLAC__metadata_object_picture_set_mime_type(obj, mime, 1) != 0 &&
+		C.FLAC__metadata_object_picture_set_description(obj, (*C.FLAC__byte)(unsafe.Pointer(desc)), 1) != 0
+	if ok && len(pic.Data) > 0 {
+		data := C.CBytes(pic.Data)
+		defer C.free(data)
+		ok = C.FLAC__metadata_object_picture_set_data(obj, (*C.FLAC__byte)(data), C.FLAC__uint32(len(pic.Data)), 1) != 0
+	}
+	if !ok {
+		C.FLAC__metadata_object_delete(obj)
+		return nil, errors.New("failed to allocate PICTURE block")
+	}
+
+	var violation *C.char
+	if C.FLAC__metadata_object_picture_is_legal(obj, &violation) == 0 {
+		C.FLAC__metadata_object_delete(obj)
+		return nil, fmt.Errorf("invalid picture: %s", C.GoString(violation))
+	}
+	return obj, nil
+}
+
+// Pictures returns the stream's embedded pictures in file order, or nil
+// if it has none. Valid after Open.
+func (d *FlacDecoder) Pictures() []*Picture {
+	return d.pictures
+}
+
+// ReadPictures returns the embedded pictures of the FLAC or Ogg FLAC file
+// at path without decoding any audio.
+func ReadPictures(path string) ([]*Picture, error) {
+	m, err := OpenMetadataEditor(path)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	return m.Pictures(), nil
+}
@@ -0,0 +1,117 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+// pictureBody encodes a PICTURE block body.
+func pictureBody(p *Picture) []byte {
+	body := binary.BigEndian.AppendUint32(nil, uint32(p.Type))
+	body = binary.BigEndian.AppendUint32(body, uint32(len(p.MIMEType)))
+	body = append(body, p.MIMEType...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(p.Description)))
+	body = append(body, p.Description...)
+	for _, v := range []uint32{p.Width, p.Height, p.Depth, p.Colors, uint32(len(p.Data))} {
+		body = binary.BigEndian.AppendUint32(body, v)
+	}
+	return append(body, p.Data...)
+}
+
+func equalPicture(a, b *Picture) bool {
+	return a.Type == b.Type && a.MIMEType == b.MIMEType && a.Description == b.Description &&
+		a.Width == b.Width && a.Height == b.Height && a.Depth == b.Depth && a.Colors == b.Colors &&
+		bytes.Equal(a.Data, b.Data)
+}
+
+func TestFlacDecoder_Pictures(t *testing.T) {
+	cover := &Picture{
+		Type:        PictureFrontCover,
+		MIMEType:    "image/png",
+		Description: "Cover — front",
+		Width:       600,
+		Height:      600,
+		Depth:       24,
+		Data:        bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 1000),
+	}
+	path, _ := encodeTestFile(t, 44100, 2, 16, 5000)
+	path = replaceMetadataBlock(t, path, 6, pictureBody(cover))
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	pics := dec.Pictures()
+	if len(pics) != 1 || !equalPicture(pics[0], cover) {
+		t.Fatalf("Pictures() = %+v, want %+v", pics, cover)
+	}
+	if got := pics[0].Type.String(); got != "Cover (front)" {
+		t.Errorf("Type = %q, want %q", got, "Cover (front)")
+	}
+
+	// Pictures are per stream, also when decoding from a reader.
+	if err := dec.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if dec.Pictures() != nil {
+		t.Error("Pictures() after Close is not nil")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("os.Open failed: %v", err)
+	}
+	defer f.Close()
+	if err := dec.OpenReader(f); err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	if pics := dec.Pictures(); len(pics) != 1 || !equalPicture(pics[0], cover) {
+		t.Errorf("Pictures() from reader = %+v", pics)
+	}
+}
+
+func TestReadPictures(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 1, 16, 5000)
+	if pics, err := ReadPictures(path); err != nil || len(pics) != 0 {
+		t.Fatalf("ReadPictures = %v, %v; want none", pics, err)
+	}
+
+	want := []*Picture{
+		{Type: PictureFrontCover, MIMEType: "image/jpeg", Width: 500, Height: 500, Depth: 24, Data: []byte{0xff, 0xd8, 0xff}},
+		{Type: PictureBandLogo, MIMEType: "image/gif", Description: "logo", Width: 64, Height: 32, Depth: 8, Colors: 256, Data: []byte("GIF89a")},
+	}
+	m := openTestEditor(t, path)
+	for _, p := range want {
+		if err := m.AddPicture(p); err != nil {
+			t.Fatalf("AddPicture failed: %v", err)
+		}
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	m.Close()
+
+	pics, err := ReadPictures(path)
+	if err != nil {
+		t.Fatalf("ReadPictures failed: %v", err)
+	}
+	if len(pics) != len(want) {
+		t.Fatalf("got %d pictures, want %d", len(pics), len(want))
+	}
+	for i := range want {
+		if !equalPicture(pics[i], want[i]) {
+			t.Errorf("picture %d = %+v, want %+v", i, pics[i], want[i])
+		}
+	}
+
+	if _, err := ReadPictures("/nonexistent/file.flac"); err == nil {
+		t.Error("ReadPictures of a missing file succeeded")
+	}
+}
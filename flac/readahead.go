@@ -0,0 +1,270 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/stream_decoder.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/drgolem/ringbuffer"
+)
+
+// readAhead is the state shared between DecodeSamples and the goroutine
+// that decodes ahead into the ring buffer. The goroutine is the ring
+// buffer's only producer and the caller of DecodeSamples its only
+// consumer; mu guards the flags below, the libFLAC decoder while the
+// goroutine is paused, and the decoder state it changes while decoding
+// (see hold).
+type readAhead struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	target int // bytes to keep buffered
+
+	busy    bool  // a frame is being decoded outside mu
+	paused  bool  // Seek has stopped decoding
+	holds   int   // getters waiting in hold
+	stop    bool  // Close has asked the goroutine to exit
+	running bool  // the goroutine has not exited yet
+	done    bool  // end of stream or error reached
+	err     error // decode error, reported once the buffer is drained
+}
+
+// SetReadAhead enables background decoding. A goroutine started by Open
+// keeps about latency worth of decoded audio in the ring buffer, so that
+// DecodeSamples only copies buffered samples and does not wait for
+// libFLAC unless the buffer runs dry. A latency of 0 disables read-ahead
+// (the default); the buffer then always holds at least one frame.
+//
+// With read-ahead, Seek and Close pause or stop the goroutine and may be
+// called at any time from the goroutine that calls DecodeSamples.
+// MD5Status, ErrorCounts, StreamErrors and TellCurrentSample may also be
+// called from other goroutines while DecodeSamples and the Decode*
+// methods built on it run; other methods may not. DecodeFrame and Run
+// are not available.
+//
+// Must be called before Open.
+func (d *FlacDecoder) SetReadAhead(latency time.Duration) error {
+	if d.channels != 0 {
+		return errors.New("read-ahead must be set before Open")
+	}
+	if latency < 0 {
+		return fmt.Errorf("invalid read-ahead latency: %v", latency)
+	}
+	d.readAheadLatency = latency
+	return nil
+}
+
+// startReadAhead starts the read-ahead goroutine for a newly opened
+// stream, if enabled.
+func (d *FlacDecoder) startReadAhead() {
+	if d.readAheadLatency <= 0 {
+		return
+	}
+
+	fb := d.frameBytes()
+	samples := int(math.Ceil(d.readAheadLatency.Seconds() * float64(d.rate)))
+	ra := &readAhead{target: max(samples, 1) * fb, running: true}
+	ra.cond = sync.NewCond(&ra.mu)
+
+	// Leave room for two more frames on top of the target, since a frame
+	// is decoded whenever the buffer holds less than the target.
+	d.reserveRing(ra.target + 2*d.maxFrameSamples()*fb)
+
+	d.readAhead = ra
+	go d.readAheadLoop(ra)
+}
+
+// stopReadAhead stops the read-ahead goroutine and waits for it to exit.
+func (d *FlacDecoder) stopReadAhead() {
+	ra := d.readAhead
+	if ra == nil {
+		return
+	}
+
+	ra.mu.Lock()
+	ra.stop = true
+	ra.cond.Broadcast()
+	for ra.running {
+		ra.cond.Wait()
+	}
+	ra.mu.Unlock()
+
+	d.readAhead = nil
+}
+
+// pause waits for the frame being decoded, if any, and keeps the goroutine
+// from decoding more until resume. The libFLAC decoder may be used
+// directly in between.
+func (ra *readAhead) pause() {
+	ra.mu.Lock()
+	ra.paused = true
+	ra.cond.Broadcast()
+	for ra.busy {
+		ra.cond.Wait()
+	}
+	ra.mu.Unlock()
+}
+
+// resume restarts decoding after pause, clearing any end of stream or
+// error state.
+func (ra *readAhead) resume() {
+	ra.mu.Lock()
+	ra.paused = false
+	ra.done = false
+	ra.err = nil
+	ra.cond.Broadcast()
+	ra.mu.Unlock()
+}
+
+// hold waits for the frame being decoded, if any, and keeps the goroutine
+// from decoding another until release. Unlike pause it leaves the
+// end of stream state alone, so getters can use it to read decoder state
+// from any goroutine.
+func (ra *readAhead) hold() {
+	ra.mu.Lock()
+	ra.holds++
+	for ra.busy {
+		ra.cond.Wait()
+	}
+}
+
+// release lets the goroutine continue after hold.
+func (ra *readAhead) release() {
+	ra.holds--
+	ra.cond.Broadcast()
+	ra.mu.Unlock()
+}
+
+// lockState holds the read-ahead goroutine, if any, so that the caller can
+// read state it changes while decoding. The returned function releases it.
+func (d *FlacDecoder) lockState() func() {
+	ra := d.readAhead
+	if ra == nil {
+		return func() {}
+	}
+	ra.hold()
+	return ra.release
+}
+
+// waitForRoom waits until the ring buffer can take n more bytes. It
+// reports false if the goroutine was paused or stopped in the meantime.
+func (ra *readAhead) waitForRoom(rb *ringbuffer.RingBuffer, n int) bool {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	for !ra.paused && !ra.stop && rb.AvailableWrite() < uint64(n) {
+		ra.cond.Wait()
+	}
+	return !ra.paused && !ra.stop
+}
+
+// readAheadLoop decodes frames until the buffer holds the target amount,
+// then waits for DecodeSamples to drain it.
+func (d *FlacDecoder) readAheadLoop(ra *readAhead) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	for {
+		for !ra.stop && (ra.paused || ra.done || ra.holds > 0 || int(d.ringBuffer.AvailableRead()) >= ra.target) {
+			ra.cond.Wait()
+		}
+		if ra.stop {
+			ra.running = false
+			ra.cond.Broadcast()
+			return
+		}
+
+		ra.busy = true
+		ra.mu.Unlock()
+		done, err := d.decodeAhead()
+		ra.mu.Lock()
+		ra.busy = false
+
+		if done {
+			ra.done = true
+			ra.err = err
+		}
+		ra.cond.Broadcast()
+	}
+}
+
+// decodeAhead decodes one frame into the ring buffer. It reports whether
+// decoding has finished, with the error that ended it if any.
+func (d *FlacDecoder) decodeAhead() (bool, error) {
+	res := C.FLAC__stream_decoder_process_single(d.decoder)
+	if d.lastError != nil {
+		err := d.lastError
+		d.lastError = nil
+		return true, err
+	}
+
+	state := C.FLAC__stream_decoder_get_state(d.decoder)
+	if state == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+		return true, d.finishStream()
+	}
+	if res == 0 {
+		return true, d.decoderError("decode samples")
+	}
+	return false, nil
+}
+
+// drainSamples is DecodeSamples with read-ahead enabled: it copies
+// samples from the ring buffer, waiting for the goroutine when it is
+// empty.
+func (d *FlacDecoder) drainSamples(samples int, audio []byte) (int, error) {
+	ra := d.readAhead
+	fb := d.frameBytes()
+	samplesRead := 0
+
+	for samplesRead < samples {
+		availableSamples := int(d.ringBuffer.AvailableRead()) / fb
+		if availableSamples > 0 {
+			n := min(availableSamples, samples-samplesRead)
+			offset := samplesRead * fb
+			if _, err := d.ringBuffer.Read(audio[offset : offset+n*fb]); err != nil {
+				return samplesRead, fmt.Errorf("failed to read from buffer: %w", err)
+			}
+			samplesRead += n
+
+			// Update the position under mu for TellCurrentSample and
+			// wake the goroutine now that there is room.
+			ra.mu.Lock()
+			d.seekFrame = nil
+			d.currentSample += int64(n)
+			ra.cond.Broadcast()
+			ra.mu.Unlock()
+			continue
+		}
+
+		ra.mu.Lock()
+		for int(d.ringBuffer.AvailableRead()) < fb && !ra.done {
+			ra.cond.Wait()
+		}
+		empty := int(d.ringBuffer.AvailableRead()) < fb
+		done, err := ra.done, ra.err
+		ra.mu.Unlock()
+
+		if empty && done {
+			if err != nil {
+				return samplesRead, err
+			}
+			if samplesRead > 0 {
+				return samplesRead, nil
+			}
+			// The MD5 result is recorded under mu for MD5Status.
+			ra.mu.Lock()
+			err = d.endOfStream()
+			ra.mu.Unlock()
+			return 0, err
+		}
+	}
+
+	return samplesRead, nil
+}
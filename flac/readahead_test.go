@@ -0,0 +1,229 @@
+package flac
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// openReadAheadDecoder opens path with read-ahead enabled.
+func openReadAheadDecoder(t *testing.T, path string, latency time.Duration) *FlacDecoder {
+	t.Helper()
+
+	dec, err := NewFlacFrameDecoder(32)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	t.Cleanup(func() { dec.Delete() })
+	if err := dec.SetReadAhead(latency); err != nil {
+		t.Fatalf("SetReadAhead failed: %v", err)
+	}
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { dec.Close() })
+	return dec
+}
+
+// decodeFileBytes decodes path without read-ahead.
+func decodeFileBytes(t *testing.T, path string) []byte {
+	t.Helper()
+
+	dec, err := NewFlacFrameDecoder(32)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+	return decodeAllBytes(t, dec)
+}
+
+func TestFlacDecoder_ReadAhead(t *testing.T) {
+	for _, latency := range []time.Duration{time.Millisecond, 100 * time.Millisecond, 2 * time.Second} {
+		t.Run(latency.String(), func(t *testing.T) {
+			path, _ := encodeTestFile(t, 44100, 2, 24, 50000)
+			want := decodeFileBytes(t, path)
+
+			dec := openReadAheadDecoder(t, path, latency)
+			if got := decodeAllBytes(t, dec); !bytes.Equal(got, want) {
+				t.Fatalf("read-ahead output differs: got %d bytes, want %d", len(got), len(want))
+			}
+			if dec.TellCurrentSample() != 50000 {
+				t.Errorf("TellCurrentSample = %d, want 50000", dec.TellCurrentSample())
+			}
+
+			// Stays at EOF.
+			buf := make([]byte, 1024*6)
+			if n, err := dec.DecodeSamples(1024, buf); n != 0 || err != io.EOF {
+				t.Errorf("DecodeSamples after EOF = %d, %v; want 0, io.EOF", n, err)
+			}
+		})
+	}
+}
+
+func TestFlacDecoder_ReadAheadSeek(t *testing.T) {
+	const numSamples = 50000
+	path, _ := encodeTestFile(t, 48000, 2, 16, numSamples)
+	want := decodeFileBytes(t, path)
+	const frameBytes = 2 * 2
+
+	dec := openReadAheadDecoder(t, path, 50*time.Millisecond)
+	buf := make([]byte, 1000*frameBytes)
+
+	// Seek while the goroutine is filling the buffer, including backwards
+	// and after reaching EOF.
+	for _, pos := range []int64{30000, 10000, 49500, 0, 40000} {
+		if _, err := dec.Seek(pos, io.SeekStart); err != nil {
+			t.Fatalf("Seek(%d) failed: %v", pos, err)
+		}
+		n, err := dec.DecodeSamples(1000, buf)
+		if err != nil && err != io.EOF {
+			t.Fatalf("DecodeSamples after Seek(%d) failed: %v", pos, err)
+		}
+		wantN := min(1000, numSamples-int(pos))
+		if n != wantN {
+			t.Fatalf("DecodeSamples after Seek(%d) = %d samples, want %d", pos, n, wantN)
+		}
+		start := int(pos) * frameBytes
+		if !bytes.Equal(buf[:n*frameBytes], want[start:start+n*frameBytes]) {
+			t.Fatalf("samples after Seek(%d) differ", pos)
+		}
+		if dec.TellCurrentSample() != pos+int64(n) {
+			t.Errorf("TellCurrentSample = %d, want %d", dec.TellCurrentSample(), pos+int64(n))
+		}
+		if pos == 49500 {
+			if _, err := dec.DecodeSamples(1000, buf); err != io.EOF {
+				t.Fatalf("DecodeSamples at end = %v, want io.EOF", err)
+			}
+		}
+	}
+}
+
+func TestFlacDecoder_ReadAheadCloseReopen(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 1, 16, 50000)
+	want := decodeFileBytes(t, path)
+
+	dec := openReadAheadDecoder(t, path, time.Second)
+
+	// Close while the goroutine is still decoding.
+	buf := make([]byte, 100*2)
+	if _, err := dec.DecodeSamples(100, buf); err != nil {
+		t.Fatalf("DecodeSamples failed: %v", err)
+	}
+	if err := dec.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := decodeAllBytes(t, dec); !bytes.Equal(got, want) {
+		t.Fatalf("output after reopen differs: got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestFlacDecoder_ReadAheadResample(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 44100)
+
+	want, _ := decodeInt32All(t, path, func(d *FlacDecoder) error {
+		return d.SetOutputSampleRate(96000, ResampleLow)
+	})
+	got, _ := decodeInt32All(t, path, func(d *FlacDecoder) error {
+		if err := d.SetReadAhead(20 * time.Millisecond); err != nil {
+			return err
+		}
+		return d.SetOutputSampleRate(96000, ResampleLow)
+	})
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFlacDecoder_ReadAheadGetters(t *testing.T) {
+	// Run with -race: the getters read state the goroutine changes while
+	// decoding.
+	path, _ := encodeTestFile(t, 44100, 2, 16, 100000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.SetReadAhead(50 * time.Millisecond); err != nil {
+		t.Fatalf("SetReadAhead failed: %v", err)
+	}
+	if err := dec.SetVerifyMD5(true); err != nil {
+		t.Fatalf("SetVerifyMD5 failed: %v", err)
+	}
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	stop := make(chan struct{})
+	polled := make(chan int64)
+	go func() {
+		var last int64
+		for {
+			select {
+			case <-stop:
+				polled <- last
+				return
+			default:
+			}
+			dec.MD5Status()
+			dec.ErrorCounts()
+			dec.StreamErrors()
+			pos := dec.TellCurrentSample()
+			if pos < last {
+				t.Errorf("TellCurrentSample went back from %d to %d", last, pos)
+			}
+			last = pos
+		}
+	}()
+
+	decodeAllBytes(t, dec)
+	close(stop)
+	if last := <-polled; last > 100000 {
+		t.Errorf("TellCurrentSample = %d, beyond the stream", last)
+	}
+	if got := dec.MD5Status(); got != MD5Verified {
+		t.Errorf("MD5Status = %v, want %v", got, MD5Verified)
+	}
+}
+
+func TestFlacDecoder_ReadAheadDecodeFrame(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 10000)
+	dec := openReadAheadDecoder(t, path, 10*time.Millisecond)
+
+	if _, err := dec.DecodeFrame(); err == nil {
+		t.Error("Expected DecodeFrame to fail with read-ahead")
+	}
+}
+
+func TestSetReadAhead_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.SetReadAhead(-time.Millisecond); err == nil {
+		t.Error("Expected error for negative latency")
+	}
+	if err := dec.SetReadAhead(200 * time.Millisecond); err != nil {
+		t.Errorf("SetReadAhead failed: %v", err)
+	}
+	if err := dec.SetReadAhead(0); err != nil {
+		t.Errorf("SetReadAhead(0) failed: %v", err)
+	}
+}
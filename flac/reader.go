@@ -0,0 +1,286 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/format.h>
+#include <FLAC/stream_decoder.h>
+#include <stdint.h>
+
+extern FLAC__StreamDecoderReadStatus
+decoderReadCallback_cgo(const FLAC__StreamDecoder *,
+                FLAC__byte *,
+                size_t *,
+                void *);
+
+extern FLAC__StreamDecoderSeekStatus
+decoderSeekCallback_cgo(const FLAC__StreamDecoder *, FLAC__uint64, void *);
+
+extern FLAC__StreamDecoderTellStatus
+decoderTellCallback_cgo(const FLAC__StreamDecoder *, FLAC__uint64 *, void *);
+
+extern FLAC__StreamDecoderLengthStatus
+decoderLengthCallback_cgo(const FLAC__StreamDecoder *, FLAC__uint64 *, void *);
+
+extern FLAC__bool
+decoderEofCallback_cgo(const FLAC__StreamDecoder *, void *);
+
+extern void
+decoderErrorCallback_cgo(const FLAC__StreamDecoder *,
+                 FLAC__StreamDecoderErrorStatus,
+                 void *);
+
+extern void
+decoderMetadataCallback_cgo(const FLAC__StreamDecoder *,
+                const FLAC__StreamMetadata *,
+                void *);
+
+extern FLAC__StreamDecoderWriteStatus
+decoderWriteCallback_cgo(const FLAC__StreamDecoder *,
+                 const FLAC__Frame *,
+                 const FLAC__int32 **,
+                 void *);
+
+// decoder_init_stream_handle wraps FLAC__stream_decoder_init_stream,
+// accepting client_data as uintptr_t instead of void*.
+// See decoder_init_file_handle for the rationale. The seek, tell and
+// length callbacks may be NULL for forward-only sources. A non-zero ogg
+// selects FLAC__stream_decoder_init_ogg_stream.
+static inline FLAC__StreamDecoderInitStatus
+decoder_init_stream_handle(FLAC__StreamDecoder *decoder,
+                           FLAC__StreamDecoderReadCallback read_cb,
+                           FLAC__StreamDecoderSeekCallback seek_cb,
+                           FLAC__StreamDecoderTellCallback tell_cb,
+                           FLAC__StreamDecoderLengthCallback length_cb,
+                           FLAC__StreamDecoderEofCallback eof_cb,
+                           FLAC__StreamDecoderWriteCallback write_cb,
+                           FLAC__StreamDecoderMetadataCallback metadata_cb,
+                           FLAC__StreamDecoderErrorCallback error_cb,
+                           int ogg,
+                           uintptr_t handle)
+{
+    if (ogg) {
+        return FLAC__stream_decoder_init_ogg_stream(
+            decoder, read_cb, seek_cb, tell_cb, length_cb, eof_cb,
+            write_cb, metadata_cb, error_cb, (void *)handle);
+    }
+    return FLAC__stream_decoder_init_stream(
+        decoder, read_cb, seek_cb, tell_cb, length_cb, eof_cb,
+        write_cb, metadata_cb, error_cb, (void *)handle);
+}
+*/
+import "C"
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// OpenReader opens a FLAC stream delivered by an io.Reader, such as stdin,
+// an HTTP response body or an in-memory buffer.
+//
+// The stream is decoded forward-only: Seek is not supported on a decoder
+// opened this way (use OpenReadSeeker for that). DecodeSamples, GetFormat
+// and TotalSamples behave exactly as they do for files. The reader must
+// remain valid until Close.
+//
+// Ogg FLAC streams are detected automatically, as with Open.
+func (d *FlacDecoder) OpenReader(r io.Reader) error {
+	if d.decoder == nil {
+		return errors.New("decoder not initialized")
+	}
+	if r == nil {
+		return errors.New("reader is nil")
+	}
+
+	d.resetState()
+
+	// Peek at the container signature without losing the bytes.
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(len(oggMagic))
+	d.reader = br
+
+	return d.initStream(false, isOggHeader(header))
+}
+
+// OpenReadSeeker opens a FLAC stream from an io.ReadSeeker, such as an
+// in-memory asset or a blob store object.
+//
+// In addition to reading, libFLAC's seek, tell, length and eof callbacks
+// are routed to the seeker, so Seek works exactly as it does for files.
+// The seeker must be positioned at the start of the stream and remain
+// valid until Close. Ogg FLAC streams are detected automatically.
+func (d *FlacDecoder) OpenReadSeeker(rs io.ReadSeeker) error {
+	if d.decoder == nil {
+		return errors.New("decoder not initialized")
+	}
+	if rs == nil {
+		return errors.New("reader is nil")
+	}
+
+	d.resetState()
+
+	header := make([]byte, len(oggMagic))
+	n, _ := io.ReadFull(rs, header)
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek to start of stream: %w", err)
+	}
+
+	d.reader = rs
+	d.seeker = rs
+
+	return d.initStream(true, isOggHeader(header[:n]))
+}
+
+// initStream initializes libFLAC in stream mode using d.reader as the
+// source. When seekable is set, d.seeker backs the seek, tell and length
+// callbacks; ogg selects the Ogg FLAC container.
+func (d *FlacDecoder) initStream(seekable, ogg bool) error {
+	if ogg {
+		if !OggSupported() {
+			return ErrOggNotSupported
+		}
+		d.ogg = true
+	}
+
+	readCallback := C.FLAC__StreamDecoderReadCallback(unsafe.Pointer(C.decoderReadCallback_cgo))
+	var seekCallback C.FLAC__StreamDecoderSeekCallback
+	var tellCallback C.FLAC__StreamDecoderTellCallback
+	var lengthCallback C.FLAC__StreamDecoderLengthCallback
+	if seekable {
+		seekCallback = C.FLAC__StreamDecoderSeekCallback(unsafe.Pointer(C.decoderSeekCallback_cgo))
+		tellCallback = C.FLAC__StreamDecoderTellCallback(unsafe.Pointer(C.decoderTellCallback_cgo))
+		lengthCallback = C.FLAC__StreamDecoderLengthCallback(unsafe.Pointer(C.decoderLengthCallback_cgo))
+	}
+	eofCallback := C.FLAC__StreamDecoderEofCallback(unsafe.Pointer(C.decoderEofCallback_cgo))
+	writeCallback := C.FLAC__StreamDecoderWriteCallback(unsafe.Pointer(C.decoderWriteCallback_cgo))
+	metadataCallback := C.FLAC__StreamDecoderMetadataCallback(unsafe.Pointer(C.decoderMetadataCallback_cgo))
+	errorCallback := C.FLAC__StreamDecoderErrorCallback(unsafe.Pointer(C.decoderErrorCallback_cgo))
+
+	status := C.decoder_init_stream_handle(d.decoder,
+		readCallback,
+		seekCallback,
+		tellCallback,
+		lengthCallback,
+		eofCallback,
+		writeCallback,
+		metadataCallback,
+		errorCallback,
+		boolToCInt(ogg),
+		C.uintptr_t(d.hDecoder),
+	)
+
+	return d.completeOpen(status)
+}
+
+//export decoderReadCallback
+func decoderReadCallback(decoder *C.FLAC__StreamDecoder, buffer *C.FLAC__byte, bytes *C.size_t, clientData unsafe.Pointer) C.FLAC__StreamDecoderReadStatus {
+	h := cgo.Handle(uintptr(clientData))
+	dec := h.Value().(*FlacDecoder)
+
+	if dec.reader == nil || *bytes == 0 {
+		*bytes = 0
+		return C.FLAC__STREAM_DECODER_READ_STATUS_ABORT
+	}
+
+	// libFLAC owns the buffer; the reader fills it in place.
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(buffer)), int(*bytes))
+	n, err := io.ReadAtLeast(dec.reader, buf, 1)
+	*bytes = C.size_t(n)
+
+	if err == io.EOF {
+		dec.readerEOF = true
+		return C.FLAC__STREAM_DECODER_READ_STATUS_END_OF_STREAM
+	}
+	if err != nil {
+		dec.setError(fmt.Errorf("read error: %w", err))
+		return C.FLAC__STREAM_DECODER_READ_STATUS_ABORT
+	}
+
+	return C.FLAC__STREAM_DECODER_READ_STATUS_CONTINUE
+}
+
+//export decoderEofCallback
+func decoderEofCallback(decoder *C.FLAC__StreamDecoder, clientData unsafe.Pointer) C.FLAC__bool {
+	h := cgo.Handle(uintptr(clientData))
+	dec := h.Value().(*FlacDecoder)
+
+	if dec.readerEOF {
+		return 1
+	}
+	return 0
+}
+
+//export decoderSeekCallback
+func decoderSeekCallback(decoder *C.FLAC__StreamDecoder, offset C.FLAC__uint64, clientData unsafe.Pointer) C.FLAC__StreamDecoderSeekStatus {
+	h := cgo.Handle(uintptr(clientData))
+	dec := h.Value().(*FlacDecoder)
+
+	if dec.seeker == nil {
+		return C.FLAC__STREAM_DECODER_SEEK_STATUS_UNSUPPORTED
+	}
+	if _, err := dec.seeker.Seek(int64(offset), io.SeekStart); err != nil {
+		return C.FLAC__STREAM_DECODER_SEEK_STATUS_ERROR
+	}
+	dec.readerEOF = false
+
+	return C.FLAC__STREAM_DECODER_SEEK_STATUS_OK
+}
+
+//export decoderTellCallback
+func decoderTellCallback(decoder *C.FLAC__StreamDecoder, offset *C.FLAC__uint64, clientData unsafe.Pointer) C.FLAC__StreamDecoderTellStatus {
+	h := cgo.Handle(uintptr(clientData))
+	dec := h.Value().(*FlacDecoder)
+
+	if dec.seeker == nil {
+		return C.FLAC__STREAM_DECODER_TELL_STATUS_UNSUPPORTED
+	}
+	pos, err := dec.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return C.FLAC__STREAM_DECODER_TELL_STATUS_ERROR
+	}
+	*offset = C.FLAC__uint64(pos)
+
+	return C.FLAC__STREAM_DECODER_TELL_STATUS_OK
+}
+
+//export decoderLengthCallback
+func decoderLengthCallback(decoder *C.FLAC__StreamDecoder, length *C.FLAC__uint64, clientData unsafe.Pointer) C.FLAC__StreamDecoderLengthStatus {
+	h := cgo.Handle(uintptr(clientData))
+	dec := h.Value().(*FlacDecoder)
+
+	if dec.seeker == nil {
+		return C.FLAC__STREAM_DECODER_LENGTH_STATUS_UNSUPPORTED
+	}
+
+	// bytes.Reader, strings.Reader and similar expose their size directly.
+	if sizer, ok := dec.seeker.(interface{ Size() int64 }); ok {
+		*length = C.FLAC__uint64(sizer.Size())
+		return C.FLAC__STREAM_DECODER_LENGTH_STATUS_OK
+	}
+
+	cur, err := dec.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return C.FLAC__STREAM_DECODER_LENGTH_STATUS_ERROR
+	}
+	end, err := dec.seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return C.FLAC__STREAM_DECODER_LENGTH_STATUS_ERROR
+	}
+	if _, err := dec.seeker.Seek(cur, io.SeekStart); err != nil {
+		return C.FLAC__STREAM_DECODER_LENGTH_STATUS_ERROR
+	}
+	*length = C.FLAC__uint64(end)
+
+	return C.FLAC__STREAM_DECODER_LENGTH_STATUS_OK
+}
+
+func boolToCInt(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
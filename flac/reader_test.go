@@ -0,0 +1,238 @@
+package flac
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encodeTestFile encodes a synthetic signal to a temporary FLAC file and
+// returns its path together with the original interleaved samples. The
+// optional setup functions configure the encoder before InitFile.
+func encodeTestFile(t testing.TB, sampleRate, channels, bps, numSamples int, setup ...func(*FlacEncoder) error) (string, []int32) {
+	t.Helper()
+
+	samples := generateTestSignal(numSamples, channels, bps)
+	return encodeSamplesFile(t, sampleRate, channels, bps, samples, setup...), samples
+}
+
+// encodeSamplesFile encodes the given interleaved samples to a temporary
+// FLAC file and returns its path. The optional setup functions configure
+// the encoder before InitFile.
+func encodeSamplesFile(t testing.TB, sampleRate, channels, bps int, samples []int32, setup ...func(*FlacEncoder) error) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.flac")
+	numSamples := len(samples) / channels
+
+	enc, err := NewFlacEncoder(sampleRate, channels, bps)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.SetTotalSamplesEstimate(int64(numSamples)); err != nil {
+		t.Fatalf("SetTotalSamplesEstimate failed: %v", err)
+	}
+	for _, fn := range setup {
+		if err := fn(enc); err != nil {
+			t.Fatalf("encoder setup failed: %v", err)
+		}
+	}
+	if err := enc.InitFile(path); err != nil {
+		t.Fatalf("InitFile failed: %v", err)
+	}
+	if err := enc.ProcessInterleaved(samples, numSamples); err != nil {
+		t.Fatalf("ProcessInterleaved failed: %v", err)
+	}
+	if err := enc.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	return path
+}
+
+// decodeAllBytes drains the decoder and returns the PCM bytes it produced.
+func decodeAllBytes(t testing.TB, dec *FlacDecoder) []byte {
+	t.Helper()
+
+	_, channels, bps := dec.GetFormat()
+	frameBytes := channels * bps / 8
+	buf := make([]byte, chunkSamples*frameBytes)
+
+	var out []byte
+	for {
+		n, err := dec.DecodeSamples(chunkSamples, buf)
+		out = append(out, buf[:n*frameBytes]...)
+		if err == io.EOF || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("DecodeSamples failed: %v", err)
+		}
+	}
+	return out
+}
+
+func TestFlacDecoder_OpenReaderMatchesFile(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 10000)
+
+	fileDec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer fileDec.Delete()
+	if err := fileDec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer fileDec.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	readerDec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer readerDec.Delete()
+	// Wrap in io.MultiReader so the decoder cannot see the concrete type.
+	if err := readerDec.OpenReader(io.MultiReader(bytes.NewReader(data))); err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer readerDec.Close()
+
+	fr, fc, fb := fileDec.GetFormat()
+	rr, rc, rb := readerDec.GetFormat()
+	if fr != rr || fc != rc || fb != rb {
+		t.Errorf("GetFormat mismatch: file %d/%d/%d, reader %d/%d/%d", fr, fc, fb, rr, rc, rb)
+	}
+	if fileDec.TotalSamples() != readerDec.TotalSamples() {
+		t.Errorf("TotalSamples mismatch: file %d, reader %d", fileDec.TotalSamples(), readerDec.TotalSamples())
+	}
+
+	filePCM := decodeAllBytes(t, fileDec)
+	readerPCM := decodeAllBytes(t, readerDec)
+	if !bytes.Equal(filePCM, readerPCM) {
+		t.Errorf("PCM mismatch: file %d bytes, reader %d bytes", len(filePCM), len(readerPCM))
+	}
+	if readerDec.TellCurrentSample() != readerDec.TotalSamples() {
+		t.Errorf("TellCurrentSample = %d, want %d", readerDec.TellCurrentSample(), readerDec.TotalSamples())
+	}
+}
+
+func TestFlacDecoder_OpenReaderInvalidData(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.OpenReader(bytes.NewReader([]byte("not a flac stream"))); err == nil {
+		t.Error("OpenReader should fail on non-FLAC data")
+		dec.Close()
+	}
+}
+
+func TestFlacDecoder_OpenReaderNil(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.OpenReader(nil); err == nil {
+		t.Error("OpenReader(nil) should fail")
+	}
+}
+
+func TestFlacDecoder_OpenReadSeekerSeek(t *testing.T) {
+	path, orig := encodeTestFile(t, 48000, 2, 16, 20000)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.OpenReadSeeker(bytes.NewReader(data)); err != nil {
+		t.Fatalf("OpenReadSeeker failed: %v", err)
+	}
+	defer dec.Close()
+
+	if dec.TotalSamples() != 20000 {
+		t.Fatalf("TotalSamples = %d, want 20000", dec.TotalSamples())
+	}
+
+	target := int64(12345)
+	pos, err := dec.Seek(target, io.SeekStart)
+	if err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if pos != target {
+		t.Errorf("Seek returned %d, want %d", pos, target)
+	}
+
+	const n = 64
+	buf := make([]byte, n*2*2)
+	got, err := dec.DecodeSamples(n, buf)
+	if err != nil {
+		t.Fatalf("DecodeSamples after seek failed: %v", err)
+	}
+	if got != n {
+		t.Fatalf("DecodeSamples returned %d samples, want %d", got, n)
+	}
+
+	decoded := make([]int32, n*2)
+	PCMToInt32(buf, 16, decoded)
+	for i, v := range decoded {
+		if want := orig[int(target)*2+i]; v != want {
+			t.Fatalf("sample %d after seek: got %d, want %d", i, v, want)
+		}
+	}
+
+	// Validation against totalSamples matches the file path
+	if _, err := dec.Seek(dec.TotalSamples(), io.SeekStart); err == nil {
+		t.Error("Seek to totalSamples should fail")
+	}
+	if _, err := dec.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek to negative position should fail")
+	}
+
+	// Seeking back to the start after reading must work too
+	if _, err := dec.Seek(0, io.SeekStart); err != nil {
+		t.Errorf("Seek to start failed: %v", err)
+	}
+}
+
+func TestFlacDecoder_OpenReaderSeekUnsupported(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 1, 16, 4096)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.OpenReader(io.MultiReader(bytes.NewReader(data))); err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer dec.Close()
+
+	if _, err := dec.Seek(100, io.SeekStart); err == nil {
+		t.Error("Seek on a forward-only reader should fail")
+	}
+}
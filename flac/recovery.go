@@ -0,0 +1,253 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/format.h>
+
+extern FLAC__uint64
+get_frame_number(const FLAC__Frame *frame);
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// ErrorPolicy selects what the decoder does when libFLAC reports a damaged
+// stream (lost sync, bad frame header, CRC mismatch, ...).
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyAbort returns the first error from DecodeSamples or
+	// DecodeFrame. This is the default.
+	ErrorPolicyAbort ErrorPolicy = iota
+
+	// ErrorPolicySkip drops damaged frames and keeps decoding. The output
+	// is shorter than the stream by the samples lost.
+	ErrorPolicySkip
+
+	// ErrorPolicyZeroFill keeps decoding and outputs silence in place of
+	// damaged or missing frames, so that the output stays aligned with the
+	// stream's timeline.
+	ErrorPolicyZeroFill
+)
+
+func (p ErrorPolicy) String() string {
+	switch p {
+	case ErrorPolicyAbort:
+		return "abort"
+	case ErrorPolicySkip:
+		return "skip"
+	case ErrorPolicyZeroFill:
+		return "zero-fill"
+	default:
+		return fmt.Sprintf("ErrorPolicy(%d)", int(p))
+	}
+}
+
+// ErrorStatus is the kind of damage libFLAC reported
+// (FLAC__StreamDecoderErrorStatus).
+type ErrorStatus int
+
+const (
+	ErrorLostSync          ErrorStatus = iota // no frame sync code where one was expected
+	ErrorBadHeader                            // frame header is invalid
+	ErrorFrameCRCMismatch                     // frame CRC-16 does not match its contents
+	ErrorUnparseableStream                    // stream uses reserved or unsupported fields
+	ErrorBadMetadata                          // a metadata block is invalid
+	ErrorOutOfBounds                          // decoded residual exceeds the sample range
+	ErrorMissingFrame                         // a frame is missing from the sequence
+)
+
+func (s ErrorStatus) String() string {
+	switch s {
+	case ErrorLostSync:
+		return "lost sync"
+	case ErrorBadHeader:
+		return "bad header"
+	case ErrorFrameCRCMismatch:
+		return "frame CRC mismatch"
+	case ErrorUnparseableStream:
+		return "unparseable stream"
+	case ErrorBadMetadata:
+		return "bad metadata"
+	case ErrorOutOfBounds:
+		return "out of bounds"
+	case ErrorMissingFrame:
+		return "missing frame"
+	default:
+		return fmt.Sprintf("unknown error status: %d", int(s))
+	}
+}
+
+// StreamError is an error libFLAC reported while decoding. Sample is the
+// position in the stream, in stream samples, of the first sample the
+// damage affects: the next sample that was due when it was detected.
+type StreamError struct {
+	Status ErrorStatus
+	Sample int64
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("FLAC decoder error: %s at sample %d", e.Status, e.Sample)
+}
+
+// Is reports whether target is the sentinel for the error's status.
+func (e *StreamError) Is(target error) bool {
+	switch e.Status {
+	case ErrorLostSync:
+		return target == ErrLostSync
+	case ErrorBadHeader:
+		return target == ErrBadHeader
+	case ErrorFrameCRCMismatch:
+		return target == ErrCRCMismatch
+	case ErrorUnparseableStream:
+		return target == ErrUnparseableStream
+	}
+	return false
+}
+
+// maxRecordedErrors bounds the list returned by StreamErrors; the counts
+// keep growing past it.
+const maxRecordedErrors = 1024
+
+// streamErrors records the errors of the current stream. It is guarded by
+// a mutex since errors are reported on the read-ahead goroutine.
+type streamErrors struct {
+	mu     sync.Mutex
+	counts map[ErrorStatus]int
+	errs   []StreamError
+}
+
+func (s *streamErrors) add(e StreamError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[ErrorStatus]int)
+	}
+	s.counts[e.Status]++
+	if len(s.errs) < maxRecordedErrors {
+		s.errs = append(s.errs, e)
+	}
+}
+
+func (s *streamErrors) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts = nil
+	s.errs = nil
+}
+
+// SetErrorPolicy selects how damaged streams are handled. Errors are
+// counted and recorded under every policy (see ErrorCounts, StreamErrors).
+//
+// Must be called before Open.
+func (d *FlacDecoder) SetErrorPolicy(policy ErrorPolicy) error {
+	if d.channels != 0 {
+		return errors.New("error policy must be set before Open")
+	}
+	if policy < ErrorPolicyAbort || policy > ErrorPolicyZeroFill {
+		return fmt.Errorf("invalid error policy: %d", int(policy))
+	}
+	d.errorPolicy = policy
+	return nil
+}
+
+// ErrorCounts returns how many errors of each status libFLAC reported
+// since the stream was opened.
+func (d *FlacDecoder) ErrorCounts() map[ErrorStatus]int {
+	d.streamErrors.mu.Lock()
+	defer d.streamErrors.mu.Unlock()
+	counts := make(map[ErrorStatus]int, len(d.streamErrors.counts))
+	for status, n := range d.streamErrors.counts {
+		counts[status] = n
+	}
+	return counts
+}
+
+// StreamErrors returns the errors libFLAC reported since the stream was
+// opened, in order, up to the first 1024.
+func (d *FlacDecoder) StreamErrors() []StreamError {
+	d.streamErrors.mu.Lock()
+	defer d.streamErrors.mu.Unlock()
+	return append([]StreamError(nil), d.streamErrors.errs...)
+}
+
+// reportStreamError handles an error status from libFLAC according to the
+// error policy.
+func (d *FlacDecoder) reportStreamError(status ErrorStatus) {
+	e := StreamError{Status: status, Sample: d.nextFrameSample}
+	d.streamErrors.add(e)
+
+	if d.errorPolicy == ErrorPolicyAbort {
+		slog.Error("FLAC decoder error callback", "error", status.String(), "status", int(status), "sample", e.Sample)
+		d.setError(&e)
+		return
+	}
+	slog.Warn("FLAC decoder error, recovering", "error", status.String(), "status", int(status), "sample", e.Sample, "policy", d.errorPolicy.String())
+
+	// Depending on the libFLAC version, a frame that fails its CRC check
+	// is either dropped or delivered with its samples zeroed. Remember
+	// where it starts so that Skip can drop it.
+	if status == ErrorFrameCRCMismatch {
+		d.badFrameSample = d.nextFrameSample
+	}
+}
+
+// recoverFrame is called from the write callback before a frame is
+// output. It fills any gap before the frame when zero-filling, and reports
+// whether the frame is a damaged one that should be dropped.
+func (d *FlacDecoder) recoverFrame(frame *C.FLAC__Frame) (bool, error) {
+	start := int64(C.get_frame_number(frame))
+	end := start + int64(frame.header.blocksize)
+
+	bad := start == d.badFrameSample
+	d.badFrameSample = -1
+
+	if d.errorPolicy == ErrorPolicyZeroFill && !d.seeking && d.frameCapture == nil && start > d.nextFrameSample {
+		if err := d.fillGap(start); err != nil {
+			return false, err
+		}
+		if d.nextFrameSample < start {
+			// Read-ahead was interrupted; drop the frame as well.
+			d.nextFrameSample = end
+			return true, nil
+		}
+	}
+	d.nextFrameSample = end
+
+	return bad && d.errorPolicy == ErrorPolicySkip, nil
+}
+
+// fillGap outputs silence from the next expected stream sample up to end.
+// Both count stream samples; the resampler converts the silence to the
+// output rate.
+func (d *FlacDecoder) fillGap(end int64) error {
+	chunk := int64(d.maxBlocksize)
+	if chunk == 0 {
+		chunk = 4096
+	}
+
+	for d.nextFrameSample < end {
+		n := min(end-d.nextFrameSample, chunk)
+		silence := make([]int32, int(n)*d.channels)
+		if d.resampler != nil {
+			silence = d.resampler.process(silence)
+		}
+
+		// The read-ahead ring buffer cannot grow; wait for the consumer to
+		// make room. Give up if the goroutine is paused or stopped: the
+		// buffered audio is about to be discarded.
+		if d.readAhead != nil && !d.readAhead.waitForRoom(d.ringBuffer, len(silence)*d.outputBytesPerSample) {
+			return nil
+		}
+		if err := d.writeSamples(silence); err != nil {
+			return err
+		}
+		d.nextFrameSample += n
+	}
+	return nil
+}
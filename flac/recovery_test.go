@@ -0,0 +1,260 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptTestFile writes a copy of path with a few bytes of audio data
+// inverted at the given fraction of the file and returns the new path.
+func corruptTestFile(t *testing.T, path string, at float64) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	off := int(float64(len(data)) * at)
+	for i := off; i < off+16; i++ {
+		data[i] ^= 0xff
+	}
+
+	out := filepath.Join(t.TempDir(), "corrupt.flac")
+	if err := os.WriteFile(out, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return out
+}
+
+// decodeWithPolicy decodes path under policy and returns the samples
+// produced, the decoder (still open) and the error that ended decoding.
+// The optional setup functions configure the decoder before Open.
+func decodeWithPolicy(t *testing.T, path string, policy ErrorPolicy, setup ...func(*FlacDecoder) error) ([]int32, *FlacDecoder, error) {
+	t.Helper()
+
+	dec, err := NewFlacFrameDecoder(32)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	t.Cleanup(func() { dec.Delete() })
+	if err := dec.SetErrorPolicy(policy); err != nil {
+		t.Fatalf("SetErrorPolicy failed: %v", err)
+	}
+	for _, fn := range setup {
+		if err := fn(dec); err != nil {
+			t.Fatalf("decoder setup failed: %v", err)
+		}
+	}
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { dec.Close() })
+
+	_, channels, _ := dec.GetFormat()
+	buf := make([]int32, chunkSamples*channels)
+	var out []int32
+	for {
+		n, err := dec.DecodeInt32(chunkSamples, buf)
+		out = append(out, buf[:n*channels]...)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return out, dec, err
+		}
+	}
+}
+
+func TestFlacDecoder_ErrorPolicyAbort(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 50000)
+	bad := corruptTestFile(t, path, 0.3)
+
+	_, dec, err := decodeWithPolicy(t, bad, ErrorPolicyAbort)
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("decode error = %v, want *StreamError", err)
+	}
+	if streamErr.Sample <= 0 || streamErr.Sample >= 50000 {
+		t.Errorf("error at sample %d, want inside the stream", streamErr.Sample)
+	}
+	if len(dec.StreamErrors()) == 0 {
+		t.Error("StreamErrors is empty")
+	}
+}
+
+func TestFlacDecoder_ErrorPolicySkip(t *testing.T) {
+	const numSamples = 50000
+	path, orig := encodeTestFile(t, 44100, 2, 16, numSamples)
+	bad := corruptTestFile(t, path, 0.3)
+
+	out, dec, err := decodeWithPolicy(t, bad, ErrorPolicySkip)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(out) >= len(orig) {
+		t.Errorf("decoded %d samples, want fewer than %d", len(out)/2, numSamples)
+	}
+
+	total := 0
+	for _, n := range dec.ErrorCounts() {
+		total += n
+	}
+	if total == 0 {
+		t.Error("ErrorCounts reports no errors")
+	}
+	if errs := dec.StreamErrors(); len(errs) != total {
+		t.Errorf("StreamErrors has %d entries, ErrorCounts %d", len(errs), total)
+	}
+
+	// Audio before the damage is intact.
+	first := dec.StreamErrors()[0].Sample
+	for i := range int(first) * 2 {
+		if out[i] != orig[i] {
+			t.Fatalf("sample %d = %d, want %d", i, out[i], orig[i])
+		}
+	}
+}
+
+func TestFlacDecoder_ErrorPolicyZeroFill(t *testing.T) {
+	const numSamples = 50000
+	path, orig := encodeTestFile(t, 44100, 2, 16, numSamples)
+	bad := corruptTestFile(t, path, 0.3)
+
+	out, dec, err := decodeWithPolicy(t, bad, ErrorPolicyZeroFill)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(out) != len(orig) {
+		t.Fatalf("decoded %d samples, want %d", len(out)/2, numSamples)
+	}
+	if len(dec.StreamErrors()) == 0 {
+		t.Fatal("StreamErrors is empty")
+	}
+
+	// Every sample is either intact or silenced, and the damaged region
+	// is silent.
+	zeroed := 0
+	for i := range orig {
+		if out[i] != orig[i] {
+			if out[i] != 0 {
+				t.Fatalf("sample %d = %d, want %d or 0", i, out[i], orig[i])
+			}
+			zeroed++
+		}
+	}
+	if zeroed == 0 {
+		t.Error("no samples were zero-filled")
+	}
+}
+
+func TestFlacDecoder_ErrorPolicyZeroFillTail(t *testing.T) {
+	const numSamples = 50000
+	path, orig := encodeTestFile(t, 44100, 1, 16, numSamples)
+
+	// Cut off the last part of the file: the missing frames are filled up
+	// to the length given in STREAMINFO.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.flac")
+	if err := os.WriteFile(truncated, data[:len(data)*9/10], 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	out, _, err := decodeWithPolicy(t, truncated, ErrorPolicyZeroFill)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(out) != len(orig) {
+		t.Fatalf("decoded %d samples, want %d", len(out), numSamples)
+	}
+	if out[len(out)-1] != 0 {
+		t.Errorf("last sample = %d, want 0", out[len(out)-1])
+	}
+}
+
+func TestFlacDecoder_ErrorPolicyZeroFillResampled(t *testing.T) {
+	// The fill target is the STREAMINFO length in stream samples, whatever
+	// the output rate: intact streams gain no silence and truncated ones
+	// are filled to the resampled length.
+	const numSamples = 50000
+	path, _ := encodeTestFile(t, 44100, 2, 16, numSamples)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.flac")
+	if err := os.WriteFile(truncated, data[:len(data)*9/10], 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	for _, rate := range []int{48000, 22050} {
+		for name, p := range map[string]string{"intact": path, "truncated": truncated} {
+			t.Run(fmt.Sprintf("%d/%s", rate, name), func(t *testing.T) {
+				out, dec, err := decodeWithPolicy(t, p, ErrorPolicyZeroFill, func(d *FlacDecoder) error {
+					return d.SetOutputSampleRate(rate, ResampleLow)
+				})
+				if err != nil {
+					t.Fatalf("decode failed: %v", err)
+				}
+				if got, want := int64(len(out)/2), dec.TotalSamples(); got != want {
+					t.Errorf("decoded %d samples, want TotalSamples() = %d", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestSetErrorPolicy_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.SetErrorPolicy(ErrorPolicy(3)); err == nil {
+		t.Error("Expected error for invalid policy")
+	}
+	if err := dec.SetErrorPolicy(ErrorPolicyZeroFill); err != nil {
+		t.Errorf("SetErrorPolicy failed: %v", err)
+	}
+	if counts := dec.ErrorCounts(); len(counts) != 0 {
+		t.Errorf("ErrorCounts before Open = %v, want empty", counts)
+	}
+}
+
+func TestStreamErrors_Unit(t *testing.T) {
+	var s streamErrors
+	for i := range maxRecordedErrors + 10 {
+		s.add(StreamError{Status: ErrorStatus(i % 2), Sample: int64(i)})
+	}
+	if len(s.errs) != maxRecordedErrors {
+		t.Errorf("recorded %d errors, want %d", len(s.errs), maxRecordedErrors)
+	}
+	if got := s.counts[ErrorLostSync] + s.counts[ErrorBadHeader]; got != maxRecordedErrors+10 {
+		t.Errorf("counted %d errors, want %d", got, maxRecordedErrors+10)
+	}
+
+	s.reset()
+	if len(s.errs) != 0 || len(s.counts) != 0 {
+		t.Error("reset left errors behind")
+	}
+}
+
+func TestStreamError_Unit(t *testing.T) {
+	err := &StreamError{Status: ErrorFrameCRCMismatch, Sample: 4096}
+	if got, want := err.Error(), "FLAC decoder error: frame CRC mismatch at sample 4096"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if got := ErrorStatus(99).String(); got != "unknown error status: 99" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := ErrorPolicyZeroFill.String(); got != "zero-fill" {
+		t.Errorf("String() = %q, want zero-fill", got)
+	}
+}
@@ -0,0 +1,158 @@
+package flac
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// RequantizeMode selects how samples are reduced to a lower bit depth when
+// the decoder's maxOutputSampleBitDepth is below the stream's depth, for
+// example when exporting a 24-bit file as 16-bit PCM.
+type RequantizeMode int
+
+const (
+	// RequantizeTruncate drops the low bits. It is the cheapest mode but
+	// adds a DC offset of half an output LSB and correlated distortion.
+	RequantizeTruncate RequantizeMode = iota
+
+	// RequantizeRound rounds to the nearest output value. No offset, but
+	// the quantization error is still correlated with the signal.
+	RequantizeRound
+
+	// RequantizeTPDF adds triangular (TPDF) dither of ±1 output LSB before
+	// rounding, which decorrelates the error into a constant white noise
+	// floor. This is what flac and sox do by default.
+	RequantizeTPDF
+
+	// RequantizeNoiseShaped adds TPDF dither and feeds the quantization
+	// error back through a second-order filter, (1 - z^-1)^2, moving the
+	// noise towards high frequencies where hearing is least sensitive.
+	RequantizeNoiseShaped
+)
+
+func (m RequantizeMode) String() string {
+	switch m {
+	case RequantizeTruncate:
+		return "truncate"
+	case RequantizeRound:
+		return "round"
+	case RequantizeTPDF:
+		return "tpdf"
+	case RequantizeNoiseShaped:
+		return "noise-shaped"
+	default:
+		return fmt.Sprintf("RequantizeMode(%d)", int(m))
+	}
+}
+
+// SetRequantizeMode selects how samples are reduced when the stream's bit
+// depth exceeds maxOutputSampleBitDepth. The default is RequantizeTruncate.
+// It has no effect when the stream is decoded at its native depth. Call it
+// before Open; changing it mid-stream affects only frames decoded
+// afterwards.
+func (d *FlacDecoder) SetRequantizeMode(mode RequantizeMode) error {
+	if mode < RequantizeTruncate || mode > RequantizeNoiseShaped {
+		return fmt.Errorf("invalid requantize mode: %d", int(mode))
+	}
+	d.requant.mode = mode
+	d.requant.reset()
+	return nil
+}
+
+// requantizer reduces decoded samples by a fixed number of bits.
+// Dither and noise-shaping state is per channel.
+type requantizer struct {
+	mode RequantizeMode
+
+	shift    uint
+	min, max int64
+
+	// Previous two quantization errors per channel, in input LSBs
+	errs [][2]int64
+
+	rng uint64
+}
+
+// configure prepares the requantizer for a stream of the given format.
+// Samples are reduced from inBits to outBits; if outBits is not lower,
+// quantize is never called.
+func (q *requantizer) configure(channels, inBits, outBits int) {
+	q.shift = 0
+	if inBits > outBits {
+		q.shift = uint(inBits - outBits)
+	}
+	q.min = -(int64(1) << (outBits - 1))
+	q.max = int64(1)<<(outBits-1) - 1
+
+	if cap(q.errs) < channels {
+		q.errs = make([][2]int64, channels)
+	}
+	q.errs = q.errs[:channels]
+	q.reset()
+
+	if q.rng == 0 {
+		q.rng = rand.Uint64() | 1
+	}
+}
+
+// reset clears the noise-shaping history, e.g. after a seek.
+func (q *requantizer) reset() {
+	for i := range q.errs {
+		q.errs[i] = [2]int64{}
+	}
+}
+
+// quantize reduces sample v of channel ch by q.shift bits.
+func (q *requantizer) quantize(v int32, ch int) int32 {
+	if q.mode == RequantizeTruncate {
+		return v >> q.shift
+	}
+
+	step := int64(1) << q.shift
+	x := int64(v)
+
+	if q.mode == RequantizeNoiseShaped {
+		e := &q.errs[ch]
+		x -= 2*e[0] - e[1]
+	}
+	u := x
+
+	if q.mode >= RequantizeTPDF {
+		// Sum of two uniform values in [0, step) is triangular over
+		// [0, 2*step); centre it on zero.
+		r := q.next()
+		x += int64(r&uint64(step-1)) + int64((r>>32)&uint64(step-1)) - (step - 1)
+	}
+
+	y := (x + step/2) >> q.shift
+	if y < q.min {
+		y = q.min
+	} else if y > q.max {
+		y = q.max
+	}
+
+	if q.mode == RequantizeNoiseShaped {
+		// Dither and rounding keep the error within 1.5 steps; anything
+		// larger comes from clipping and would destabilize the loop.
+		err := y<<q.shift - u
+		if err > 2*step {
+			err = 2 * step
+		} else if err < -2*step {
+			err = -2 * step
+		}
+		e := &q.errs[ch]
+		e[1] = e[0]
+		e[0] = err
+	}
+
+	return int32(y)
+}
+
+// next returns the next value of a xorshift64* generator. It is cheap
+// enough to run per sample and needs no locking.
+func (q *requantizer) next() uint64 {
+	q.rng ^= q.rng >> 12
+	q.rng ^= q.rng << 25
+	q.rng ^= q.rng >> 27
+	return q.rng * 0x2545F4914F6CDD1D
+}
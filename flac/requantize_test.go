@@ -0,0 +1,180 @@
+package flac
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+func TestRequantizer_Unit(t *testing.T) {
+	// 24 -> 16 bits: one output LSB is 256 input LSBs.
+	input := []int32{0, 127, 128, 255, 256, -1, -128, -129, 8388607, -8388608}
+
+	t.Run("truncate", func(t *testing.T) {
+		var q requantizer
+		q.configure(1, 24, 16)
+		want := []int32{0, 0, 0, 0, 1, -1, -1, -1, 32767, -32768}
+		for i, v := range input {
+			if got := q.quantize(v, 0); got != want[i] {
+				t.Errorf("quantize(%d) = %d, want %d", v, got, want[i])
+			}
+		}
+	})
+
+	t.Run("round", func(t *testing.T) {
+		q := requantizer{mode: RequantizeRound}
+		q.configure(1, 24, 16)
+		want := []int32{0, 0, 1, 1, 1, 0, 0, -1, 32767, -32768}
+		for i, v := range input {
+			if got := q.quantize(v, 0); got != want[i] {
+				t.Errorf("quantize(%d) = %d, want %d", v, got, want[i])
+			}
+		}
+	})
+}
+
+func TestRequantizer_DitherUnit(t *testing.T) {
+	for _, mode := range []RequantizeMode{RequantizeTPDF, RequantizeNoiseShaped} {
+		t.Run(mode.String(), func(t *testing.T) {
+			q := requantizer{mode: mode}
+			q.configure(2, 24, 16)
+
+			const n = 100000
+			var sum, sumSq float64
+			outputs := map[int32]bool{}
+			for i := 0; i < n; i++ {
+				// A constant input between two output steps
+				v := int32(1000*256 + 64)
+				got := q.quantize(v, i%2)
+				outputs[got] = true
+
+				e := float64(got)*256 - float64(v)
+				// TPDF stays within 1.5 LSB; second-order shaping can
+				// amplify that by up to 4.
+				if math.Abs(e) > 6*256 {
+					t.Fatalf("error %v exceeds 6 output LSBs", e/256)
+				}
+				sum += e
+				sumSq += e * e
+			}
+
+			// Dither must spread the output over several values without
+			// introducing a DC offset.
+			if len(outputs) < 2 {
+				t.Errorf("dither produced a single output value")
+			}
+			if mean := sum / n / 256; math.Abs(mean) > 0.05 {
+				t.Errorf("mean error = %v LSB, want ~0", mean)
+			}
+			if rms := math.Sqrt(sumSq/n) / 256; rms < 0.3 {
+				t.Errorf("rms error = %v LSB, too low for dither", rms)
+			}
+		})
+	}
+}
+
+func TestRequantizer_NoiseShapingUnit(t *testing.T) {
+	// The shaped error spectrum should rise with frequency: the error of
+	// a shaped signal has much less energy after a lowpass (moving sum)
+	// than plain TPDF does.
+	lowBandEnergy := func(mode RequantizeMode) float64 {
+		q := requantizer{mode: mode}
+		q.configure(1, 24, 16)
+
+		const n = 1 << 16
+		errs := make([]float64, n)
+		for i := range errs {
+			v := int32(3000000 * math.Sin(float64(i)*0.01))
+			errs[i] = float64(q.quantize(v, 0))*256 - float64(v)
+		}
+
+		// A 64-sample moving sum passes only the lowest frequencies:
+		// white error keeps 64x its variance, (1 - z^-1)^2 shaped error 4x.
+		const window = 64
+		var energy, sum float64
+		for i, e := range errs {
+			sum += e
+			if i >= window {
+				sum -= errs[i-window]
+				energy += sum * sum
+			}
+		}
+		return energy
+	}
+
+	tpdf := lowBandEnergy(RequantizeTPDF)
+	shaped := lowBandEnergy(RequantizeNoiseShaped)
+	if shaped >= tpdf/4 {
+		t.Errorf("low-band error energy: shaped %v, tpdf %v; want shaped well below tpdf", shaped, tpdf)
+	}
+}
+
+func TestSetRequantizeMode_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	for _, mode := range []RequantizeMode{RequantizeTruncate, RequantizeRound, RequantizeTPDF, RequantizeNoiseShaped} {
+		if err := dec.SetRequantizeMode(mode); err != nil {
+			t.Errorf("SetRequantizeMode(%s) failed: %v", mode, err)
+		}
+	}
+	if err := dec.SetRequantizeMode(RequantizeMode(-1)); err == nil {
+		t.Error("SetRequantizeMode(-1) should fail")
+	}
+	if err := dec.SetRequantizeMode(RequantizeNoiseShaped + 1); err == nil {
+		t.Error("SetRequantizeMode out of range should fail")
+	}
+}
+
+func TestFlacDecoder_Requantize24To16(t *testing.T) {
+	numSamples := 8000
+	path, orig := encodeTestFile(t, 48000, 2, 24, numSamples)
+
+	for _, mode := range []RequantizeMode{RequantizeTruncate, RequantizeRound, RequantizeTPDF, RequantizeNoiseShaped} {
+		t.Run(mode.String(), func(t *testing.T) {
+			dec, err := NewFlacFrameDecoder(16)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.SetRequantizeMode(mode); err != nil {
+				t.Fatalf("SetRequantizeMode failed: %v", err)
+			}
+			if err := dec.Open(path); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer dec.Close()
+
+			out := make([]int32, numSamples*2)
+			n, err := dec.DecodeInt32(numSamples, out)
+			if err != nil && err != io.EOF {
+				t.Fatalf("DecodeInt32 failed: %v", err)
+			}
+			if n != numSamples {
+				t.Fatalf("Decoded %d samples, want %d", n, numSamples)
+			}
+
+			for i, v := range out {
+				exact := float64(orig[i]) / 256
+				diff := float64(v) - exact
+				switch mode {
+				case RequantizeTruncate:
+					if v != orig[i]>>8 {
+						t.Fatalf("sample %d = %d, want %d", i, v, orig[i]>>8)
+					}
+				case RequantizeRound:
+					if math.Abs(diff) > 0.5 {
+						t.Fatalf("sample %d = %d, more than 0.5 LSB from %v", i, v, exact)
+					}
+				default:
+					if math.Abs(diff) > 6 {
+						t.Fatalf("sample %d = %d, more than 6 LSB from %v", i, v, exact)
+					}
+				}
+			}
+		})
+	}
+}
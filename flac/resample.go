@@ -0,0 +1,304 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ResampleQuality selects the trade-off between resampler speed and
+// filter quality.
+type ResampleQuality int
+
+const (
+	// ResampleLow uses a short filter (about 60 dB stopband attenuation),
+	// suitable for previews and voice.
+	ResampleLow ResampleQuality = iota
+
+	// ResampleMedium gives about 90 dB attenuation, transparent for 16-bit
+	// playback.
+	ResampleMedium
+
+	// ResampleHigh gives about 120 dB attenuation and a steeper cutoff,
+	// for 24-bit material and mastering.
+	ResampleHigh
+)
+
+func (q ResampleQuality) String() string {
+	switch q {
+	case ResampleLow:
+		return "low"
+	case ResampleMedium:
+		return "medium"
+	case ResampleHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("ResampleQuality(%d)", int(q))
+	}
+}
+
+// resampleParams holds the filter design for a quality level.
+type resampleParams struct {
+	zeroCrossings int     // filter half-length, in cutoff periods
+	phases        int     // table resolution, points per input sample
+	beta          float64 // Kaiser window shape
+	rolloff       float64 // cutoff as a fraction of the lower Nyquist rate
+}
+
+var resampleQualities = map[ResampleQuality]resampleParams{
+	ResampleLow:    {zeroCrossings: 8, phases: 128, beta: 5.7, rolloff: 0.90},
+	ResampleMedium: {zeroCrossings: 16, phases: 256, beta: 8.6, rolloff: 0.945},
+	ResampleHigh:   {zeroCrossings: 32, phases: 512, beta: 12.0, rolloff: 0.97},
+}
+
+// SetOutputSampleRate resamples the decoder output to rate Hz using a
+// windowed-sinc (Kaiser) filter of the given quality. Streams already at
+// rate are passed through unchanged; a rate of 0 disables resampling.
+//
+// With resampling active, GetFormat reports the output rate, and
+// TotalSamples, TellCurrentSample and Seek all use output-rate sample
+// units. Resampling applies to DecodeSamples and the APIs built on it;
+// DecodeFrame returns native frames and is not available while resampling.
+//
+// Must be called before Open.
+func (d *FlacDecoder) SetOutputSampleRate(rate int, quality ResampleQuality) error {
+	if d.channels != 0 {
+		return errors.New("output sample rate must be set before Open")
+	}
+	if rate < 0 || rate > 655350 {
+		return fmt.Errorf("invalid output sample rate: %d (must be 1-655350, or 0 to disable)", rate)
+	}
+	if _, ok := resampleQualities[quality]; !ok {
+		return fmt.Errorf("invalid resample quality: %d", int(quality))
+	}
+
+	d.outputRate = rate
+	d.resampleQuality = quality
+	return nil
+}
+
+// configureResampler sets up sample rate conversion for a newly opened
+// stream, converting the rate and length to output units.
+func (d *FlacDecoder) configureResampler() {
+	d.resampler = nil
+	if d.outputRate == 0 || int64(d.outputRate) == d.rate {
+		return
+	}
+
+	d.resampler = newResampler(int(d.rate), d.outputRate, d.channels, d.bitsPerSample, d.resampleQuality)
+	d.resampler.reset(0, 0)
+	d.rate = int64(d.outputRate)
+	if d.totalSamples > 0 {
+		d.totalSamples = d.resampler.outputLength(d.totalSamples)
+	}
+}
+
+// resampler converts interleaved int32 samples between two sample rates.
+//
+// Output sample n is taken at input time n*inRate/outRate, computed with
+// integer arithmetic so that the position never drifts. The filter kernel
+// is tabulated at a fixed number of points per input sample and linearly
+// interpolated between them.
+type resampler struct {
+	inRate, outRate int64
+	channels        int
+
+	table  []float64 // kernel at x = i/phases, for x in [0, width]
+	phases float64
+	width  float64 // kernel half-width in input samples
+	taps   int     // ceil(width)
+
+	// Buffered input per channel; hist[ch][0] is input sample histStart.
+	hist      [][]float64
+	histStart int64
+	inEnd     int64 // index of the next input sample
+
+	outPos  int64 // index of the next output sample
+	flushed bool
+
+	min, max float64 // output clamp range
+	out      []int32
+}
+
+func newResampler(inRate, outRate, channels, bitsPerSample int, quality ResampleQuality) *resampler {
+	p := resampleQualities[quality]
+
+	// The cutoff sits below the lower of the two Nyquist rates; when
+	// downsampling the kernel widens in input samples accordingly.
+	fc := p.rolloff
+	if outRate < inRate {
+		fc *= float64(outRate) / float64(inRate)
+	}
+	width := float64(p.zeroCrossings) / fc
+
+	r := &resampler{
+		inRate:   int64(inRate),
+		outRate:  int64(outRate),
+		channels: channels,
+		phases:   float64(p.phases),
+		width:    width,
+		taps:     int(math.Ceil(width)),
+		hist:     make([][]float64, channels),
+		min:      -math.Ldexp(1, bitsPerSample-1),
+		max:      math.Ldexp(1, bitsPerSample-1) - 1,
+	}
+
+	n := int(math.Ceil(width*r.phases)) + 2
+	r.table = make([]float64, n)
+	i0Beta := besselI0(p.beta)
+	for i := range r.table {
+		x := float64(i) / r.phases
+		if x > width {
+			break
+		}
+		w := x / width
+		r.table[i] = fc * sinc(fc*x) * besselI0(p.beta*math.Sqrt(1-w*w)) / i0Beta
+	}
+
+	return r
+}
+
+// outputLength returns the number of output samples for n input samples.
+func (r *resampler) outputLength(n int64) int64 {
+	return (n*r.outRate + r.inRate - 1) / r.inRate
+}
+
+// inputPosition returns the input sample to start decoding from so that
+// output sample n can be produced with a full filter history.
+func (r *resampler) inputPosition(n int64) int64 {
+	pos := n*r.inRate/r.outRate - int64(r.taps) + 1
+	if pos < 0 {
+		pos = 0
+	}
+	return pos
+}
+
+// reset discards all state; the next input sample is inPos and the next
+// output sample outPos.
+func (r *resampler) reset(inPos, outPos int64) {
+	for ch := range r.hist {
+		r.hist[ch] = r.hist[ch][:0]
+	}
+	r.histStart = inPos
+	r.inEnd = inPos
+	r.outPos = outPos
+	r.flushed = false
+}
+
+// process appends interleaved input samples and returns the interleaved
+// output samples that can now be computed. The result is valid until the
+// next call.
+func (r *resampler) process(in []int32) []int32 {
+	frames := len(in) / r.channels
+	for ch := range r.hist {
+		for i := 0; i < frames; i++ {
+			r.hist[ch] = append(r.hist[ch], float64(in[i*r.channels+ch]))
+		}
+	}
+	r.inEnd += int64(frames)
+
+	return r.produce(false)
+}
+
+// flush returns the remaining output samples at end of stream, treating
+// the input after the last sample as silence.
+func (r *resampler) flush() []int32 {
+	if r.flushed {
+		return r.out[:0]
+	}
+	r.flushed = true
+	return r.produce(true)
+}
+
+func (r *resampler) produce(final bool) []int32 {
+	r.out = r.out[:0]
+
+	for {
+		num := r.outPos * r.inRate
+		base := num / r.outRate
+		if final {
+			if num >= r.inEnd*r.outRate {
+				break
+			}
+		} else if base+int64(r.taps) >= r.inEnd {
+			break
+		}
+		frac := float64(num%r.outRate) / float64(r.outRate)
+
+		for ch := 0; ch < r.channels; ch++ {
+			r.out = append(r.out, r.filter(ch, base, frac))
+		}
+		r.outPos++
+	}
+
+	// Keep only the history the next output still needs.
+	keep := (r.outPos*r.inRate)/r.outRate - int64(r.taps) + 1
+	if drop := keep - r.histStart; drop > 0 {
+		if drop > int64(len(r.hist[0])) {
+			drop = int64(len(r.hist[0]))
+		}
+		for ch := range r.hist {
+			n := copy(r.hist[ch], r.hist[ch][drop:])
+			r.hist[ch] = r.hist[ch][:n]
+		}
+		r.histStart += drop
+	}
+
+	return r.out
+}
+
+// filter computes one output sample of channel ch at input time base+frac.
+func (r *resampler) filter(ch int, base int64, frac float64) int32 {
+	hist := r.hist[ch]
+	var acc float64
+	for k := base - int64(r.taps) + 1; k <= base+int64(r.taps); k++ {
+		idx := k - r.histStart
+		if idx < 0 || idx >= int64(len(hist)) {
+			continue // before the stream start or past its end: silence
+		}
+		x := math.Abs(float64(base-k) + frac)
+		if x >= r.width {
+			continue
+		}
+		acc += hist[idx] * r.kernel(x)
+	}
+
+	v := math.Round(acc)
+	if v < r.min {
+		v = r.min
+	} else if v > r.max {
+		v = r.max
+	}
+	return int32(v)
+}
+
+// kernel returns the filter value at distance x, interpolated from the
+// table.
+func (r *resampler) kernel(x float64) float64 {
+	pos := x * r.phases
+	i := int(pos)
+	f := pos - float64(i)
+	return r.table[i] + f*(r.table[i+1]-r.table[i])
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 is the zeroth-order modified Bessel function of the first kind,
+// used by the Kaiser window.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	half := x / 2
+	for k := 1; k < 64; k++ {
+		term *= half / float64(k)
+		sum += term * term
+		if term*term < sum*1e-17 {
+			break
+		}
+	}
+	return sum
+}
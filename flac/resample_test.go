@@ -0,0 +1,220 @@
+package flac
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+// runResampler feeds in through r in blocks and returns all output.
+func runResampler(r *resampler, in []int32, block int) []int32 {
+	var out []int32
+	for len(in) > 0 {
+		n := min(block*r.channels, len(in))
+		out = append(out, r.process(in[:n])...)
+		in = in[n:]
+	}
+	return append(out, r.flush()...)
+}
+
+func TestResampler_LengthUnit(t *testing.T) {
+	tests := []struct {
+		inRate, outRate int
+	}{
+		{44100, 48000},
+		{48000, 44100},
+		{96000, 48000},
+		{22050, 44100},
+	}
+	for _, tt := range tests {
+		r := newResampler(tt.inRate, tt.outRate, 2, 16, ResampleMedium)
+		r.reset(0, 0)
+
+		const n = 10007
+		in := make([]int32, n*2)
+		out := runResampler(r, in, 1000)
+		if got, want := int64(len(out)/2), r.outputLength(n); got != want {
+			t.Errorf("%d->%d: produced %d samples, outputLength = %d", tt.inRate, tt.outRate, got, want)
+		}
+		if want := int64(math.Ceil(float64(n) * float64(tt.outRate) / float64(tt.inRate))); r.outputLength(n) != want {
+			t.Errorf("%d->%d: outputLength(%d) = %d, want %d", tt.inRate, tt.outRate, n, r.outputLength(n), want)
+		}
+		if pos := r.inputPosition(0); pos != 0 {
+			t.Errorf("%d->%d: inputPosition(0) = %d, want 0", tt.inRate, tt.outRate, pos)
+		}
+	}
+}
+
+// resampleTolerance is the largest relative error expected from each
+// quality level, in line with its stopband attenuation.
+var resampleTolerance = map[ResampleQuality]float64{
+	ResampleLow:    1e-3,
+	ResampleMedium: 5e-5,
+	ResampleHigh:   2e-6,
+}
+
+func TestResampler_DCGainUnit(t *testing.T) {
+	const level = 1 << 22
+	for q, tol := range resampleTolerance {
+		r := newResampler(44100, 48000, 1, 24, q)
+		r.reset(0, 0)
+
+		in := make([]int32, 20000)
+		for i := range in {
+			in[i] = level
+		}
+		out := runResampler(r, in, 4096)
+
+		// Skip the edges, where the filter sees the silence around the
+		// stream.
+		for i := 200; i < len(out)-200; i++ {
+			if rel := math.Abs(float64(out[i]-level)) / level; rel > tol {
+				t.Fatalf("%v: output %d = %d, want %d", q, i, out[i], level)
+			}
+		}
+	}
+}
+
+func TestResampler_SineUnit(t *testing.T) {
+	const (
+		inRate  = 44100
+		outRate = 48000
+		freq    = 1000.0
+		amp     = 4e6
+	)
+	for q, tol := range resampleTolerance {
+		r := newResampler(inRate, outRate, 2, 24, q)
+		r.reset(0, 0)
+
+		in := make([]int32, 2*inRate/2)
+		for i := 0; i < len(in)/2; i++ {
+			v := int32(math.Round(amp * math.Sin(2*math.Pi*freq*float64(i)/inRate)))
+			in[2*i] = v
+			in[2*i+1] = -v
+		}
+		out := runResampler(r, in, 1152)
+
+		var maxErr float64
+		for i := 500; i < len(out)/2-500; i++ {
+			want := amp * math.Sin(2*math.Pi*freq*float64(i)/outRate)
+			maxErr = max(maxErr, math.Abs(float64(out[2*i])-want), math.Abs(float64(out[2*i+1])+want))
+		}
+		if rel := maxErr / amp; rel > tol {
+			t.Errorf("%v: relative error %.2g, want <= %.2g", q, rel, tol)
+		}
+	}
+}
+
+func TestResampler_BlockSizeIndependentUnit(t *testing.T) {
+	in := generateTestSignal(20000, 2, 16)
+
+	ref := newResampler(48000, 44100, 2, 16, ResampleMedium)
+	ref.reset(0, 0)
+	want := runResampler(ref, in, len(in))
+
+	r := newResampler(48000, 44100, 2, 16, ResampleMedium)
+	r.reset(0, 0)
+	got := runResampler(r, in, 37)
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSetOutputSampleRate_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.SetOutputSampleRate(-1, ResampleMedium); err == nil {
+		t.Error("Expected error for negative rate")
+	}
+	if err := dec.SetOutputSampleRate(700000, ResampleMedium); err == nil {
+		t.Error("Expected error for rate above 655350")
+	}
+	if err := dec.SetOutputSampleRate(48000, ResampleQuality(7)); err == nil {
+		t.Error("Expected error for invalid quality")
+	}
+	if err := dec.SetOutputSampleRate(48000, ResampleHigh); err != nil {
+		t.Errorf("SetOutputSampleRate failed: %v", err)
+	}
+	if err := dec.SetOutputSampleRate(0, ResampleLow); err != nil {
+		t.Errorf("SetOutputSampleRate(0) failed: %v", err)
+	}
+}
+
+func TestFlacDecoder_Resample(t *testing.T) {
+	const numSamples = 44100
+	path, _ := encodeTestFile(t, 44100, 2, 16, numSamples)
+
+	// decodeInt32All checks that the decoded length matches TotalSamples.
+	out, channels := decodeInt32All(t, path, func(d *FlacDecoder) error {
+		return d.SetOutputSampleRate(48000, ResampleMedium)
+	})
+	if channels != 2 {
+		t.Fatalf("channels = %d, want 2", channels)
+	}
+	if total := len(out) / channels; total != 48000 {
+		t.Errorf("decoded %d samples, want 48000", total)
+	}
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.SetOutputSampleRate(48000, ResampleMedium); err != nil {
+		t.Fatalf("SetOutputSampleRate failed: %v", err)
+	}
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	if rate, _, _ := dec.GetFormat(); rate != 48000 {
+		t.Errorf("GetFormat rate = %d, want 48000", rate)
+	}
+	if _, err := dec.DecodeFrame(); err == nil {
+		t.Error("Expected DecodeFrame to fail while resampling")
+	}
+
+	// Seeking uses output samples and matches a continuous decode.
+	const seekTo = 30000
+	if _, err := dec.Seek(seekTo, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	buf := make([]int32, 1000*channels)
+	n, err := dec.DecodeInt32(1000, buf)
+	if err != nil && err != io.EOF {
+		t.Fatalf("DecodeInt32 failed: %v", err)
+	}
+	if n != 1000 {
+		t.Fatalf("decoded %d samples after seek, want 1000", n)
+	}
+	for i, v := range buf {
+		if want := out[seekTo*channels+i]; v != want {
+			t.Fatalf("sample %d after seek = %d, want %d", i, v, want)
+		}
+	}
+}
+
+func TestFlacDecoder_ResampleSameRate(t *testing.T) {
+	const numSamples = 5000
+	path, orig := encodeTestFile(t, 48000, 1, 16, numSamples)
+
+	out, _ := decodeInt32All(t, path, func(d *FlacDecoder) error {
+		return d.SetOutputSampleRate(48000, ResampleHigh)
+	})
+	for i := range orig {
+		if out[i] != orig[i] {
+			t.Fatalf("sample %d = %d, want %d", i, out[i], orig[i])
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/stream_decoder.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DecodeInt32 decodes the specified number of samples (per channel) as
+// interleaved int32 values.
+//
+// Each value is sign-extended to the container size reported by GetFormat,
+// which is the stream's native depth unless the decoder was created with a
+// lower maxOutputSampleBitDepth. For a 24-bit stream the values are in
+// [-8388608, 8388607]. For depths that are not a multiple of 8 the values
+// follow SetSampleJustification: a left-justified 12-bit stream yields
+// 16-bit values with the low 4 bits clear, a right-justified one yields
+// values in [-2048, 2047].
+//
+// The out slice must hold at least samples * channels values. Returns the
+// number of samples per channel decoded and any error encountered; io.EOF
+// is returned once the stream is exhausted, as with DecodeSamples.
+//
+// Frames are decoded straight into out; only the part of a frame that
+// does not fit is kept in the ring buffer for the next call. With
+// read-ahead the samples are taken from the ring buffer.
+func (d *FlacDecoder) DecodeInt32(samples int, out []int32) (int, error) {
+	if err := d.checkSampleRequest(samples); err != nil {
+		return 0, err
+	}
+	if len(out) < samples*d.channels {
+		return 0, fmt.Errorf("output slice too small: need %d values, got %d", samples*d.channels, len(out))
+	}
+
+	if d.readAhead != nil {
+		buf := d.pcmScratch(samples)
+		n, err := d.DecodeSamples(samples, buf)
+		if n > 0 {
+			PCMToInt32(buf[:n*d.channels*d.outputBytesPerSample], d.outputBytesPerSample*8, out)
+		}
+		return n, err
+	}
+
+	d.lastError = nil
+	samplesRead := 0
+	for samplesRead < samples {
+		if d.lastError != nil {
+			err := d.lastError
+			d.lastError = nil
+			return samplesRead, err
+		}
+
+		// Samples left over from an earlier frame come first.
+		buffered, err := d.readBuffered(out[samplesRead*d.channels:], samples-samplesRead)
+		if err != nil {
+			return samplesRead, err
+		}
+		if buffered > 0 {
+			samplesRead += buffered
+			continue
+		}
+
+		state := C.FLAC__stream_decoder_get_state(d.decoder)
+		if state == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+			n, err := d.decodeDirect(out[samplesRead*d.channels:samples*d.channels], d.finishStream)
+			samplesRead += n
+			if err != nil {
+				return samplesRead, err
+			}
+			if n > 0 {
+				continue
+			}
+			if samplesRead > 0 {
+				return samplesRead, nil
+			}
+			return 0, d.endOfStream()
+		}
+
+		var res C.FLAC__bool
+		n, err := d.decodeDirect(out[samplesRead*d.channels:samples*d.channels], func() error {
+			res = C.FLAC__stream_decoder_process_single(d.decoder)
+			return nil
+		})
+		samplesRead += n
+		if err != nil {
+			return samplesRead, err
+		}
+		if res == 0 {
+			if d.lastError != nil {
+				err := d.lastError
+				d.lastError = nil
+				return samplesRead, err
+			}
+			if C.FLAC__stream_decoder_get_state(d.decoder) == C.FLAC__STREAM_DECODER_END_OF_STREAM {
+				continue
+			}
+			return samplesRead, d.decoderError("decode samples")
+		}
+	}
+	return samplesRead, nil
+}
+
+// decodeDirect runs decode, which outputs samples through writeSamples,
+// with out as the destination for them, and returns the number of samples
+// per channel written to out.
+func (d *FlacDecoder) decodeDirect(out []int32, decode func() error) (int, error) {
+	d.directOut = out
+	err := decode()
+	n := (len(out) - len(d.directOut)) / d.channels
+	d.directOut = nil
+
+	if n > 0 {
+		d.seekFrame = nil
+		d.currentSample += int64(n)
+	}
+	return n, err
+}
+
+// readBuffered moves up to samples samples from the ring buffer to out
+// and returns how many it moved.
+func (d *FlacDecoder) readBuffered(out []int32, samples int) (int, error) {
+	n := min(int(d.ringBuffer.AvailableRead())/d.frameBytes(), samples)
+	if n == 0 {
+		return 0, nil
+	}
+
+	buf := d.pcmScratch(n)
+	if _, err := d.ringBuffer.Read(buf); err != nil {
+		return 0, fmt.Errorf("failed to read from buffer: %w", err)
+	}
+	PCMToInt32(buf, d.outputBytesPerSample*8, out)
+	d.seekFrame = nil
+	d.currentSample += int64(n)
+	return n, nil
+}
+
+// DecodeInt32Planar is the planar variant of DecodeInt32: out[ch] receives
+// the samples of channel ch, matching libFLAC's per-channel buffers.
+//
+// The out slice must have at least one entry per channel, each holding at
+// least samples values. Returns the number of samples per channel decoded.
+func (d *FlacDecoder) DecodeInt32Planar(samples int, out [][]int32) (int, error) {
+	if err := d.checkSampleRequest(samples); err != nil {
+		return 0, err
+	}
+	if len(out) < d.channels {
+		return 0, fmt.Errorf("output has %d channels, need %d", len(out), d.channels)
+	}
+	for ch := 0; ch < d.channels; ch++ {
+		if len(out[ch]) < samples {
+			return 0, fmt.Errorf("channel %d slice too small: need %d values, got %d", ch, samples, len(out[ch]))
+		}
+	}
+
+	if cap(d.int32Buf) < samples*d.channels {
+		d.int32Buf = make([]int32, samples*d.channels)
+	}
+	interleaved := d.int32Buf[:samples*d.channels]
+
+	n, err := d.DecodeInt32(samples, interleaved)
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < d.channels; ch++ {
+			out[ch][i] = interleaved[i*d.channels+ch]
+		}
+	}
+	return n, err
+}
+
+// DecodeFloat32 decodes the specified number of samples (per channel) as
+// interleaved float32 values normalized to [-1, 1).
+//
+// Samples are divided by 2^(bits-1), where bits is the output bit depth
+// (the significant bits when right-justified, otherwise the container), so
+// the most negative value maps to exactly -1 and the full scale positive
+// value to just below 1. The out slice must hold at least
+// samples * channels values. Returns the number of samples per channel
+// decoded, with io.EOF at end of stream as for DecodeSamples.
+func (d *FlacDecoder) DecodeFloat32(samples int, out []float32) (int, error) {
+	ints, err := d.floatSource(samples, len(out))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := d.DecodeInt32(samples, ints)
+	scale := float32(sampleScale(d.floatScaleBits()))
+	for i := 0; i < n*d.channels; i++ {
+		out[i] = float32(ints[i]) * scale
+	}
+	return n, err
+}
+
+// DecodeFloat64 is the float64 variant of DecodeFloat32.
+func (d *FlacDecoder) DecodeFloat64(samples int, out []float64) (int, error) {
+	ints, err := d.floatSource(samples, len(out))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := d.DecodeInt32(samples, ints)
+	scale := sampleScale(d.floatScaleBits())
+	for i := 0; i < n*d.channels; i++ {
+		out[i] = float64(ints[i]) * scale
+	}
+	return n, err
+}
+
+// floatSource validates a float decode request and returns the int32
+// scratch buffer the samples are decoded into before scaling.
+func (d *FlacDecoder) floatSource(samples, outLen int) ([]int32, error) {
+	if err := d.checkSampleRequest(samples); err != nil {
+		return nil, err
+	}
+	if outLen < samples*d.channels {
+		return nil, fmt.Errorf("output slice too small: need %d values, got %d", samples*d.channels, outLen)
+	}
+
+	if cap(d.int32Buf) < samples*d.channels {
+		d.int32Buf = make([]int32, samples*d.channels)
+	}
+	return d.int32Buf[:samples*d.channels], nil
+}
+
+// floatScaleBits returns the bit depth that full scale corresponds to in
+// the int32 values produced by DecodeInt32.
+func (d *FlacDecoder) floatScaleBits() int {
+	if d.justification == RightJustified && d.outputBits > 0 {
+		return d.outputBits
+	}
+	return d.outputBytesPerSample * 8
+}
+
+// sampleScale returns the factor that maps a signed integer sample of the
+// given bit depth to [-1, 1).
+func sampleScale(bits int) float64 {
+	return 1.0 / float64(uint64(1)<<(bits-1))
+}
+
+// checkSampleRequest validates a sample count against the open stream,
+// guarding against integer overflow in the derived buffer sizes.
+func (d *FlacDecoder) checkSampleRequest(samples int) error {
+	if samples <= 0 {
+		return errors.New("samples must be positive")
+	}
+	if d.channels <= 0 || d.outputBytesPerSample <= 0 {
+		return errors.New("decoder not initialized: channels or outputBytesPerSample invalid")
+	}
+
+	const maxInt = int(^uint(0) >> 1)
+	if samples > maxInt/(d.channels*d.outputBytesPerSample) {
+		return errors.New("samples value too large, would cause integer overflow")
+	}
+	return nil
+}
+
+// pcmScratch returns a reusable byte buffer large enough for the given
+// number of samples at the current output format.
+func (d *FlacDecoder) pcmScratch(samples int) []byte {
+	size := samples * d.channels * d.outputBytesPerSample
+	if cap(d.pcmBuf) < size {
+		d.pcmBuf = make([]byte, size)
+	}
+	return d.pcmBuf[:size]
+}
@@ -0,0 +1,446 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestDecodeInt32_MatchesEncoderInput(t *testing.T) {
+	for _, bps := range []int{8, 16, 24} {
+		t.Run(formatTestName(44100, 2, bps), func(t *testing.T) {
+			numSamples := 6000
+			path, orig := encodeTestFile(t, 44100, 2, bps, numSamples)
+
+			dec, err := NewFlacFrameDecoder(32)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.Open(path); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer dec.Close()
+
+			out := make([]int32, numSamples*2)
+			total := 0
+			for total < numSamples {
+				n, err := dec.DecodeInt32(1000, out[total*2:])
+				total += n
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("DecodeInt32 failed: %v", err)
+				}
+			}
+
+			if total != numSamples {
+				t.Fatalf("Decoded %d samples, want %d", total, numSamples)
+			}
+			for i := range orig {
+				if out[i] != orig[i] {
+					t.Fatalf("Sample %d mismatch: got %d, want %d", i, out[i], orig[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeInt32Planar(t *testing.T) {
+	numSamples := 3000
+	path, orig := encodeTestFile(t, 48000, 2, 16, numSamples)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	out := [][]int32{make([]int32, numSamples), make([]int32, numSamples)}
+	n, err := dec.DecodeInt32Planar(numSamples, out)
+	if err != nil && err != io.EOF {
+		t.Fatalf("DecodeInt32Planar failed: %v", err)
+	}
+	if n != numSamples {
+		t.Fatalf("Decoded %d samples, want %d", n, numSamples)
+	}
+
+	for i := 0; i < numSamples; i++ {
+		for ch := 0; ch < 2; ch++ {
+			if out[ch][i] != orig[i*2+ch] {
+				t.Fatalf("Channel %d sample %d: got %d, want %d", ch, i, out[ch][i], orig[i*2+ch])
+			}
+		}
+	}
+}
+
+func TestDecodeInt32_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	// Not opened yet
+	if _, err := dec.DecodeInt32(10, make([]int32, 20)); err == nil {
+		t.Error("DecodeInt32 before Open should fail")
+	}
+
+	dec.channels = 2
+	dec.outputBytesPerSample = 2
+
+	if _, err := dec.DecodeInt32(0, make([]int32, 20)); err == nil {
+		t.Error("DecodeInt32 with zero samples should fail")
+	}
+	if _, err := dec.DecodeInt32(100, make([]int32, 20)); err == nil {
+		t.Error("DecodeInt32 with small output should fail")
+	}
+	if _, err := dec.DecodeInt32Planar(10, [][]int32{make([]int32, 10)}); err == nil {
+		t.Error("DecodeInt32Planar with missing channels should fail")
+	}
+	if _, err := dec.DecodeInt32Planar(10, [][]int32{make([]int32, 10), make([]int32, 5)}); err == nil {
+		t.Error("DecodeInt32Planar with short channel slice should fail")
+	}
+}
+
+func TestSampleScale(t *testing.T) {
+	tests := []struct {
+		bits int
+		min  int32
+		max  int32
+	}{
+		{8, -128, 127},
+		{12, -2048, 2047},
+		{16, -32768, 32767},
+		{20, -524288, 524287},
+		{24, -8388608, 8388607},
+		{32, -2147483648, 2147483647},
+	}
+
+	for _, tt := range tests {
+		scale := sampleScale(tt.bits)
+		if got := float64(tt.min) * scale; got != -1 {
+			t.Errorf("%d-bit min scales to %v, want -1", tt.bits, got)
+		}
+		if got := float64(tt.max) * scale; got >= 1 || got < 0.99 {
+			t.Errorf("%d-bit max scales to %v, want just below 1", tt.bits, got)
+		}
+		if got := float32(float64(tt.max) * scale); got > 1 {
+			t.Errorf("%d-bit max as float32 = %v, exceeds 1", tt.bits, got)
+		}
+	}
+}
+
+func TestDecodeFloat_MatchesEncoderInput(t *testing.T) {
+	for _, bps := range []int{8, 16, 24} {
+		t.Run(formatTestName(48000, 2, bps), func(t *testing.T) {
+			numSamples := 4000
+			path, orig := encodeTestFile(t, 48000, 2, bps, numSamples)
+			full := float64(int64(1) << (bps - 1))
+
+			dec, err := NewFlacFrameDecoder(32)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.Open(path); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer dec.Close()
+
+			out64 := make([]float64, numSamples*2)
+			n, err := dec.DecodeFloat64(numSamples/2, out64)
+			if err != nil {
+				t.Fatalf("DecodeFloat64 failed: %v", err)
+			}
+			out32 := make([]float32, numSamples*2)
+			m, err := dec.DecodeFloat32(numSamples-n, out32[n*2:])
+			if err != nil && err != io.EOF {
+				t.Fatalf("DecodeFloat32 failed: %v", err)
+			}
+			if n+m != numSamples {
+				t.Fatalf("Decoded %d samples, want %d", n+m, numSamples)
+			}
+
+			for i := 0; i < n*2; i++ {
+				if v := out64[i]; v < -1 || v >= 1 || v*full != float64(orig[i]) {
+					t.Fatalf("float64 sample %d = %v, want %v", i, v, float64(orig[i])/full)
+				}
+			}
+			for i := n * 2; i < numSamples*2; i++ {
+				want := float32(float64(orig[i]) / full)
+				if v := out32[i]; v < -1 || v > 1 || v != want {
+					t.Fatalf("float32 sample %d = %v, want %v", i, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeFloat_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	dec.channels = 2
+	dec.outputBytesPerSample = 2
+
+	if _, err := dec.DecodeFloat32(100, make([]float32, 10)); err == nil {
+		t.Error("DecodeFloat32 with small output should fail")
+	}
+	if _, err := dec.DecodeFloat64(-1, make([]float64, 10)); err == nil {
+		t.Error("DecodeFloat64 with negative samples should fail")
+	}
+}
+
+func TestDecodeInt32_OddBitDepths(t *testing.T) {
+	tests := []struct {
+		bps           int
+		maxOutput     int
+		justification SampleJustification
+		wantContainer int
+		wantBits      int
+		scale         func(int32) int32
+	}{
+		{12, 32, LeftJustified, 16, 12, func(v int32) int32 { return v << 4 }},
+		{12, 32, RightJustified, 16, 12, func(v int32) int32 { return v }},
+		{20, 32, LeftJustified, 24, 20, func(v int32) int32 { return v << 4 }},
+		{20, 32, RightJustified, 24, 20, func(v int32) int32 { return v }},
+		{20, 16, LeftJustified, 16, 16, func(v int32) int32 { return v >> 4 }},
+		{12, 8, RightJustified, 8, 8, func(v int32) int32 { return v >> 4 }},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%dbit_max%d_%s", tt.bps, tt.maxOutput, tt.justification)
+		t.Run(name, func(t *testing.T) {
+			numSamples := 3000
+			path, orig := encodeTestFile(t, 44100, 2, tt.bps, numSamples)
+
+			dec, err := NewFlacFrameDecoder(tt.maxOutput)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.SetSampleJustification(tt.justification); err != nil {
+				t.Fatalf("SetSampleJustification failed: %v", err)
+			}
+			if err := dec.Open(path); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer dec.Close()
+
+			_, _, bits := dec.GetFormat()
+			container, significant := dec.GetSampleBits()
+			if bits != tt.wantContainer || container != tt.wantContainer || significant != tt.wantBits {
+				t.Fatalf("format bits = %d, GetSampleBits = (%d, %d), want container %d, significant %d",
+					bits, container, significant, tt.wantContainer, tt.wantBits)
+			}
+
+			// The byte stream must be exactly container-sized per sample.
+			pcm := decodeAllBytes(t, dec)
+			if want := numSamples * 2 * tt.wantContainer / 8; len(pcm) != want {
+				t.Fatalf("decoded %d bytes, want %d", len(pcm), want)
+			}
+
+			decoded := make([]int32, numSamples*2)
+			PCMToInt32(pcm, tt.wantContainer, decoded)
+			for i, v := range decoded {
+				if want := tt.scale(orig[i]); v != want {
+					t.Fatalf("sample %d = %d, want %d (source %d)", i, v, want, orig[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeFloat_OddBitDepthScale(t *testing.T) {
+	for _, j := range []SampleJustification{LeftJustified, RightJustified} {
+		t.Run(j.String(), func(t *testing.T) {
+			numSamples := 2000
+			path, orig := encodeTestFile(t, 48000, 1, 20, numSamples)
+
+			dec, err := NewFlacFrameDecoder(24)
+			if err != nil {
+				t.Fatalf("Failed to create decoder: %v", err)
+			}
+			defer dec.Delete()
+			if err := dec.SetSampleJustification(j); err != nil {
+				t.Fatalf("SetSampleJustification failed: %v", err)
+			}
+			if err := dec.Open(path); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer dec.Close()
+
+			out := make([]float64, numSamples)
+			n, err := dec.DecodeFloat64(numSamples, out)
+			if err != nil && err != io.EOF {
+				t.Fatalf("DecodeFloat64 failed: %v", err)
+			}
+			if n != numSamples {
+				t.Fatalf("Decoded %d samples, want %d", n, numSamples)
+			}
+
+			// Full scale is the same regardless of justification.
+			for i, v := range out {
+				if want := float64(orig[i]) / (1 << 19); v != want {
+					t.Fatalf("sample %d = %v, want %v", i, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetSampleJustification_Validation(t *testing.T) {
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+
+	if err := dec.SetSampleJustification(RightJustified); err != nil {
+		t.Errorf("SetSampleJustification(RightJustified) failed: %v", err)
+	}
+	if err := dec.SetSampleJustification(SampleJustification(7)); err == nil {
+		t.Error("SetSampleJustification with invalid value should fail")
+	}
+}
+
+func TestDecodeInt32_MatchesDecodeSamples(t *testing.T) {
+	// DecodeInt32 decodes frames straight into its slice and keeps the
+	// rest of a frame in the ring buffer; mixing it with DecodeSamples and
+	// uneven request sizes must give the same samples as the byte path.
+	tests := []struct {
+		name      string
+		bps       int
+		maxOutput int
+		setup     func(*FlacDecoder) error
+	}{
+		{"16bit", 16, 32, nil},
+		{"12bit_left", 12, 32, nil},
+		{"12bit_right", 12, 32, func(d *FlacDecoder) error { return d.SetSampleJustification(RightJustified) }},
+		{"24to16", 24, 16, nil},
+		{"resampled", 16, 32, func(d *FlacDecoder) error { return d.SetOutputSampleRate(48000, ResampleLow) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _ := encodeTestFile(t, 44100, 2, tt.bps, 20000)
+			open := func() *FlacDecoder {
+				dec, err := NewFlacFrameDecoder(tt.maxOutput)
+				if err != nil {
+					t.Fatalf("Failed to create decoder: %v", err)
+				}
+				t.Cleanup(func() { dec.Delete() })
+				if tt.setup != nil {
+					if err := tt.setup(dec); err != nil {
+						t.Fatalf("setup failed: %v", err)
+					}
+				}
+				if err := dec.Open(path); err != nil {
+					t.Fatalf("Open failed: %v", err)
+				}
+				t.Cleanup(func() { dec.Close() })
+				return dec
+			}
+
+			dec := open()
+			_, channels, bits := dec.GetFormat()
+			pcm := decodeAllBytes(t, dec)
+			want := make([]int32, len(pcm)/(bits/8))
+			PCMToInt32(pcm, bits, want)
+
+			dec = open()
+			var got []int32
+			buf := make([]int32, 5000*channels)
+			raw := make([]byte, 5000*channels*bits/8)
+			for i := 0; ; i++ {
+				size := []int{1, 777, 4096, 5000}[i%4]
+				var n int
+				var err error
+				if i%5 == 4 {
+					n, err = dec.DecodeSamples(size, raw)
+					PCMToInt32(raw[:n*channels*bits/8], bits, buf)
+				} else {
+					n, err = dec.DecodeInt32(size, buf)
+				}
+				got = append(got, buf[:n*channels]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("decode failed: %v", err)
+				}
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("DecodeInt32 gave %d values differing from DecodeSamples' %d", len(got), len(want))
+			}
+			if dec.TellCurrentSample() != int64(len(want)/channels) {
+				t.Errorf("TellCurrentSample = %d, want %d", dec.TellCurrentSample(), len(want)/channels)
+			}
+		})
+	}
+}
+
+func TestJustifySamples_Unit(t *testing.T) {
+	tests := []struct {
+		bytes   int
+		justify uint
+		in      []int32
+		want    []int32
+	}{
+		{2, 0, []int32{-32768, 32767}, []int32{-32768, 32767}},
+		{2, 4, []int32{-2048, 2047, 1}, []int32{-32768, 32752, 16}},
+		{3, 4, []int32{-524288, 524287}, []int32{-8388608, 8388592}},
+		{1, 4, []int32{-8, 7}, []int32{-128, 112}},
+		{4, 0, []int32{-1 << 31, 1<<31 - 1}, []int32{-1 << 31, 1<<31 - 1}},
+		// Out of range values wrap as when packed into bytes.
+		{2, 0, []int32{32768}, []int32{-32768}},
+	}
+
+	for _, tt := range tests {
+		got := slices.Clone(tt.in)
+		justifySamples(got, tt.bytes, tt.justify)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("justifySamples(%v, %d, %d) = %v, want %v", tt.in, tt.bytes, tt.justify, got, tt.want)
+		}
+	}
+}
+
+func TestSampleShifts_Unit(t *testing.T) {
+	tests := []struct {
+		streamBits, outputBits, bytes int
+		justification                 SampleJustification
+		reduce, justify               uint
+	}{
+		{16, 16, 2, LeftJustified, 0, 0},
+		{12, 12, 2, LeftJustified, 0, 4},
+		{12, 12, 2, RightJustified, 0, 0},
+		{20, 20, 3, LeftJustified, 0, 4},
+		{24, 16, 2, LeftJustified, 8, 0},
+		{20, 16, 2, RightJustified, 4, 0},
+		{4, 4, 1, LeftJustified, 0, 4},
+	}
+
+	for _, tt := range tests {
+		d := &FlacDecoder{
+			bitsPerSample:        tt.streamBits,
+			outputBits:           tt.outputBits,
+			outputBytesPerSample: tt.bytes,
+			justification:        tt.justification,
+		}
+		reduce, justify := d.sampleShifts()
+		if reduce != tt.reduce || justify != tt.justify {
+			t.Errorf("%d->%d bits in %d bytes (%s): shifts = (%d, %d), want (%d, %d)",
+				tt.streamBits, tt.outputBits, tt.bytes, tt.justification, reduce, justify, tt.reduce, tt.justify)
+		}
+	}
+}
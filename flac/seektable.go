@@ -0,0 +1,89 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/metadata.h>
+
+extern FLAC__StreamMetadata_SeekTable *
+get_seek_table(FLAC__StreamMetadata *metadata);
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// SeekPoint is an entry of a SEEKTABLE metadata block: the first sample of
+// a frame and where the frame starts.
+type SeekPoint struct {
+	SampleNumber uint64 // first sample of the target frame
+	StreamOffset uint64 // bytes from the first frame header to the target frame
+	FrameSamples uint32 // samples in the target frame
+}
+
+// seekPointPlaceholder is the sample number of an unused seek point
+// (FLAC__STREAM_METADATA_SEEKPOINT_PLACEHOLDER).
+const seekPointPlaceholder = 1<<64 - 1
+
+// SeekTable returns the seek points of the stream's SEEKTABLE block, or
+// nil if it has none. Placeholder points are left out. Valid after Open.
+func (d *FlacDecoder) SeekTable() []SeekPoint {
+	return d.seekTable
+}
+
+// SeekTable returns the seek points of the SEEKTABLE block, or nil if
+// there is none. Placeholder points are left out.
+func (m *MetadataEditor) SeekTable() []SeekPoint {
+	var points []SeekPoint
+	m.each(func(it *C.FLAC__Metadata_Iterator, block *C.FLAC__StreamMetadata) {
+		if points == nil && block._type == C.FLAC__METADATA_TYPE_SEEKTABLE {
+			points = parseSeekTable(block)
+		}
+	})
+	return points
+}
+
+// parseSeekTable copies a SEEKTABLE block into Go memory, dropping
+// placeholder points. It never returns nil.
+func parseSeekTable(metadata *C.FLAC__StreamMetadata) []SeekPoint {
+	st := C.get_seek_table(metadata)
+	points := make([]SeekPoint, 0, int(st.num_points))
+	for _, p := range unsafe.Slice(st.points, int(st.num_points)) {
+		if uint64(p.sample_number) == seekPointPlaceholder {
+			continue
+		}
+		points = append(points, SeekPoint{
+			SampleNumber: uint64(p.sample_number),
+			StreamOffset: uint64(p.stream_offset),
+			FrameSamples: uint32(p.frame_samples),
+		})
+	}
+	return points
+}
+
+// newSeekTableObject builds a SEEKTABLE template for a stream of
+// totalSamples: a point every interval samples if interval is set,
+// otherwise n evenly spaced points. libFLAC fills in the points while
+// encoding. The caller owns the result, as for newPictureObject.
+func newSeekTableObject(interval, n int, totalSamples uint64) (*C.FLAC__StreamMetadata, error) {
+	obj := C.FLAC__metadata_object_new(C.FLAC__METADATA_TYPE_SEEKTABLE)
+	if obj == nil {
+		return nil, errors.New("failed to allocate SEEKTABLE block")
+	}
+
+	var ok C.FLAC__bool
+	if interval > 0 {
+		ok = C.FLAC__metadata_object_seektable_template_append_spaced_points_by_samples(
+			obj, C.uint32_t(interval), C.FLAC__uint64(totalSamples))
+	} else {
+		ok = C.FLAC__metadata_object_seektable_template_append_spaced_points(
+			obj, C.uint32_t(n), C.FLAC__uint64(totalSamples))
+	}
+	// Sorting also drops duplicate points, which short streams produce.
+	if ok == 0 || C.FLAC__metadata_object_seektable_template_sort(obj, 1) == 0 {
+		C.FLAC__metadata_object_delete(obj)
+		return nil, errors.New("failed to allocate SEEKTABLE block")
+	}
+	return obj, nil
+}
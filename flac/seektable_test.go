@@ -0,0 +1,134 @@
+package flac
+
+import (
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSeekTable_Interval(t *testing.T) {
+	const numSamples = 10 * 44100
+	path, samples := encodeTestFile(t, 44100, 1, 16, numSamples, func(enc *FlacEncoder) error {
+		return enc.SetSeekTableInterval(time.Second)
+	})
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	points := dec.SeekTable()
+	if len(points) != 10 {
+		t.Fatalf("got %d seek points, want 10: %+v", len(points), points)
+	}
+	if points[0] != (SeekPoint{0, 0, points[0].FrameSamples}) || points[0].FrameSamples == 0 {
+		t.Errorf("first point = %+v, want the first frame", points[0])
+	}
+	for i, p := range points {
+		// Each point is the frame that contains its target sample.
+		target := uint64(i * 44100)
+		if p.SampleNumber > target || p.SampleNumber+uint64(p.FrameSamples) <= target {
+			t.Errorf("point %d = %+v does not cover sample %d", i, p, target)
+		}
+		if i > 0 && p.StreamOffset <= points[i-1].StreamOffset {
+			t.Errorf("point %d offset %d not after %d", i, p.StreamOffset, points[i-1].StreamOffset)
+		}
+	}
+
+	m := openTestEditor(t, path)
+	if got := m.SeekTable(); !slices.Equal(got, points) {
+		t.Errorf("MetadataEditor.SeekTable() = %+v, want %+v", got, points)
+	}
+
+	// Seeking through the table lands on the right samples.
+	const pos = 7*44100 + 123
+	if _, err := dec.Seek(pos, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	var out []int32
+	buf := make([]int32, 1000)
+	for len(out) < 1000 {
+		n, err := dec.DecodeInt32(1000-len(out), buf)
+		if err != nil {
+			t.Fatalf("DecodeInt32 failed: %v", err)
+		}
+		out = append(out, buf[:n]...)
+	}
+	if !slices.Equal(out, samples[pos:pos+1000]) {
+		t.Error("samples after Seek differ")
+	}
+}
+
+func TestSeekTable_Points(t *testing.T) {
+	const numSamples = 5 * 44100
+	path, _ := encodeTestFile(t, 44100, 1, 16, numSamples, func(enc *FlacEncoder) error {
+		return enc.SetSeekTablePoints(5)
+	})
+
+	m := openTestEditor(t, path)
+	points := m.SeekTable()
+	if len(points) != 5 {
+		t.Fatalf("got %d seek points, want 5: %+v", len(points), points)
+	}
+	for i, p := range points {
+		target := uint64(i * numSamples / 5)
+		if p.SampleNumber > target || p.SampleNumber+uint64(p.FrameSamples) <= target {
+			t.Errorf("point %d = %+v does not cover sample %d", i, p, target)
+		}
+	}
+}
+
+func TestSeekTable_None(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 1, 16, 5000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+	if points := dec.SeekTable(); points != nil {
+		t.Errorf("SeekTable() = %+v, want nil", points)
+	}
+}
+
+func TestSeekTable_RequiresTotalSamples(t *testing.T) {
+	enc, err := NewFlacEncoder(44100, 1, 16)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+	if err := enc.SetSeekTablePoints(10); err != nil {
+		t.Fatalf("SetSeekTablePoints failed: %v", err)
+	}
+	if err := enc.InitFile(filepath.Join(t.TempDir(), "out.flac")); err == nil {
+		t.Error("InitFile with a seek table and no total samples succeeded")
+	}
+}
+
+func TestSeekTable_Validation(t *testing.T) {
+	enc, err := NewFlacEncoder(44100, 1, 16)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.SetSeekTableInterval(-time.Second); err == nil {
+		t.Error("SetSeekTableInterval accepted a negative interval")
+	}
+	for _, n := range []int{-1, maxMetadataBlockLength/18 + 1} {
+		if err := enc.SetSeekTablePoints(n); err == nil {
+			t.Errorf("SetSeekTablePoints(%d) succeeded", n)
+		}
+	}
+}
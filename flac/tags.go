@@ -0,0 +1,104 @@
+package flac
+
+/*
+#cgo pkg-config: flac
+#include <FLAC/format.h>
+
+extern FLAC__StreamMetadata_VorbisComment *
+get_vorbis_comment(FLAC__StreamMetadata *metadata);
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// Tags holds the contents of a VORBIS_COMMENT metadata block: the vendor
+// string of the encoder and the comment fields in file order. A name may
+// appear more than once (several ARTIST fields, for example); names are
+// matched case-insensitively.
+type Tags struct {
+	Vendor string
+	Fields []TagField
+}
+
+// TagField is a single NAME=value comment. Name keeps the case it was
+// stored with.
+type TagField struct {
+	Name  string
+	Value string
+}
+
+// Get returns the first value of the named field, or "" if it is absent.
+func (t *Tags) Get(name string) string {
+	if t == nil {
+		return ""
+	}
+	for _, f := range t.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// GetAll returns every value of the named field, in file order.
+func (t *Tags) GetAll(name string) []string {
+	if t == nil {
+		return nil
+	}
+	var values []string
+	for _, f := range t.Fields {
+		if strings.EqualFold(f.Name, name) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// Has reports whether the named field is present.
+func (t *Tags) Has(name string) bool {
+	if t == nil {
+		return false
+	}
+	for _, f := range t.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags returns the stream's Vorbis comments, or nil if it has no
+// VORBIS_COMMENT block. Valid after Open.
+func (d *FlacDecoder) Tags() *Tags {
+	return d.tags
+}
+
+// parseVorbisComment copies a VORBIS_COMMENT block into Go memory.
+// Entries without a '=' separator are not valid comments and are skipped.
+func parseVorbisComment(metadata *C.FLAC__StreamMetadata) *Tags {
+	vc := C.get_vorbis_comment(metadata)
+	tags := &Tags{Vendor: entryString(vc.vendor_string)}
+
+	entries := unsafe.Slice(vc.comments, int(vc.num_comments))
+	tags.Fields = make([]TagField, 0, len(entries))
+	for _, e := range entries {
+		name, value, ok := strings.Cut(entryString(e), "=")
+		if !ok {
+			continue
+		}
+		tags.Fields = append(tags.Fields, TagField{Name: name, Value: value})
+	}
+	return tags
+}
+
+// entryString returns a comment entry, which is length-delimited UTF-8
+// and not necessarily NUL-terminated, as a Go string.
+func entryString(e C.FLAC__StreamMetadata_VorbisComment_Entry) string {
+	if e.length == 0 || e.entry == nil {
+		return ""
+	}
+	return C.GoStringN((*C.char)(unsafe.Pointer(e.entry)), C.int(e.length))
+}
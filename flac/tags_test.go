@@ -0,0 +1,175 @@
+package flac
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// replaceMetadataBlock writes a copy of path in which all metadata blocks
+// of the given type are replaced by one with body, placed right after
+// STREAMINFO, and returns the new path.
+func replaceMetadataBlock(t *testing.T, path string, blockType byte, body []byte) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data[:4]) != "fLaC" {
+		t.Fatal("not a native FLAC file")
+	}
+
+	// Split the metadata into blocks without their headers' last flag.
+	type block struct {
+		typ  byte
+		body []byte
+	}
+	var blocks []block
+	pos := 4
+	for {
+		header := data[pos]
+		length := int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		if typ := header & 0x7f; typ != blockType {
+			blocks = append(blocks, block{typ, data[pos+4 : pos+4+length]})
+		}
+		pos += 4 + length
+		if header&0x80 != 0 {
+			break
+		}
+	}
+	blocks = slices.Insert(blocks, 1, block{blockType, body})
+
+	out := []byte("fLaC")
+	for i, b := range blocks {
+		header := b.typ
+		if i == len(blocks)-1 {
+			header |= 0x80
+		}
+		n := len(b.body)
+		out = append(out, header, byte(n>>16), byte(n>>8), byte(n))
+		out = append(out, b.body...)
+	}
+	out = append(out, data[pos:]...)
+
+	newPath := filepath.Join(t.TempDir(), "meta.flac")
+	if err := os.WriteFile(newPath, out, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return newPath
+}
+
+// vorbisCommentBody encodes a VORBIS_COMMENT block body.
+func vorbisCommentBody(vendor string, comments ...string) []byte {
+	body := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	body = append(body, vendor...)
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(comments)))
+	for _, c := range comments {
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(c)))
+		body = append(body, c...)
+	}
+	return body
+}
+
+func TestFlacDecoder_Tags(t *testing.T) {
+	path, _ := encodeTestFile(t, 44100, 2, 16, 5000)
+	tagged := replaceMetadataBlock(t, path, 4, vorbisCommentBody("test vendor",
+		"ARTIST=First Artist",
+		"album=Some Album",
+		"Artist=Second Artist",
+		"TRACKNUMBER=7",
+		"COMMENT=a=b",
+		"EMPTY=",
+		"INVALID",
+		"TITLE=Ünïcödé",
+	))
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(tagged); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	tags := dec.Tags()
+	if tags == nil {
+		t.Fatal("Tags() = nil")
+	}
+	if tags.Vendor != "test vendor" {
+		t.Errorf("Vendor = %q, want %q", tags.Vendor, "test vendor")
+	}
+	want := []TagField{
+		{"ARTIST", "First Artist"},
+		{"album", "Some Album"},
+		{"Artist", "Second Artist"},
+		{"TRACKNUMBER", "7"},
+		{"COMMENT", "a=b"},
+		{"EMPTY", ""},
+		{"TITLE", "Ünïcödé"},
+	}
+	if !slices.Equal(tags.Fields, want) {
+		t.Errorf("Fields = %q, want %q", tags.Fields, want)
+	}
+
+	if got := tags.GetAll("artist"); !slices.Equal(got, []string{"First Artist", "Second Artist"}) {
+		t.Errorf("GetAll(artist) = %q", got)
+	}
+	if got := tags.Get("ALBUM"); got != "Some Album" {
+		t.Errorf("Get(ALBUM) = %q", got)
+	}
+	if !tags.Has("empty") || tags.Has("INVALID") || tags.Has("GENRE") {
+		t.Error("Has reports the wrong fields")
+	}
+
+	// Tags are per stream.
+	if err := dec.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if dec.Tags() != nil {
+		t.Error("Tags() after Close is not nil")
+	}
+}
+
+func TestFlacDecoder_TagsVendorOnly(t *testing.T) {
+	// libFLAC writes a VORBIS_COMMENT block with just its vendor string
+	// when the encoder is given none.
+	path, _ := encodeTestFile(t, 44100, 1, 16, 5000)
+
+	dec, err := NewFlacFrameDecoder(16)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	defer dec.Delete()
+	if err := dec.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dec.Close()
+
+	tags := dec.Tags()
+	if tags == nil || tags.Vendor == "" {
+		t.Fatalf("Tags() = %+v, want libFLAC's vendor string", tags)
+	}
+	if len(tags.Fields) != 0 {
+		t.Errorf("Fields = %q, want none", tags.Fields)
+	}
+}
+
+func TestTags_Unit(t *testing.T) {
+	var nilTags *Tags
+	if nilTags.Get("ARTIST") != "" || nilTags.GetAll("ARTIST") != nil || nilTags.Has("ARTIST") {
+		t.Error("nil Tags is not empty")
+	}
+
+	tags := &Tags{Fields: []TagField{{"Genre", "Jazz"}, {"GENRE", "Blues"}}}
+	if got := tags.Get("genre"); got != "Jazz" {
+		t.Errorf("Get(genre) = %q, want Jazz", got)
+	}
+	if got := tags.GetAll("GeNrE"); !slices.Equal(got, []string{"Jazz", "Blues"}) {
+		t.Errorf("GetAll(GeNrE) = %q", got)
+	}
+}